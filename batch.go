@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	youtube "github.com/knadh/go-get-youtube/youtube"
+)
+
+// the outcome of a batch item
+type result struct {
+	input, id string
+	skipped   bool
+	video     *youtube.Video
+	err       error
+}
+
+// read video IDs or URLs from a batch file, one per line. Blank lines and
+// lines starting with #, ; or ] are ignored. "-" reads from stdin.
+func readBatch(filename string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var items []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.ContainsAny(line[:1], "#;]") {
+			continue
+		}
+		items = append(items, line)
+	}
+	return items, scanner.Err()
+}
+
+// download the videos in a batch file, followed by extra items, with a pool
+// of workers and print a report. Returns the number of failed downloads.
+func downloadBatch(filename string, extra []string, concurrency int, s settings) int {
+	var items []string
+	if filename != "" {
+		var err error
+		if items, err = readBatch(filename); err != nil {
+			printErr(err)
+			return 1
+		}
+	}
+	items = append(items, extra...)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		results = make([]result, len(items))
+		jobs    = make(chan int)
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = downloadItem(items[i], s)
+			}
+		}()
+	}
+
+	for i := range items {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// report
+	var t tally
+	fmt.Fprintln(console)
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			fmt.Fprintf(console, "FAILED\t%s\t%s\n", r.input, r.err)
+			t.fail(r.input, r.video, r.err)
+		case r.skipped:
+			fmt.Fprintf(console, "SKIPPED\t%s\n", r.input)
+			t.skip(r.input, "archived")
+		default:
+			fmt.Fprintf(console, "OK\t%s\n", r.input)
+			t.ok(r.input, r.video)
+		}
+	}
+	fmt.Fprintf(console, "\nBatch done: %d downloaded, %d skipped, %d failed\n", t.downloaded, t.skipped, len(t.failed))
+
+	return len(t.failed)
+}
+
+// download a single batch item
+func downloadItem(input string, s settings) result {
+	r := result{input: input, id: youtube.VideoId(input)}
+
+	if archived(r.id, s.option) {
+		fmt.Fprintf(console, "[%s] Skipping archived video\n", r.id)
+		r.skipped = true
+		return r
+	}
+
+	fmt.Fprintf(console, "[%s] Fetching metadata\n", r.id)
+	r.video, r.err = downloadEntry(r.id, nil, s)
+	return r
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	youtube "github.com/knadh/go-get-youtube/youtube"
+)
+
+// download the videos on a channel tab published after a date (all videos
+// if after is zero) and print a summary. Returns the number of failed downloads.
+func downloadChannel(channel, tab string, after time.Time, s settings) int {
+	fmt.Fprintln(console, "Hold on ...")
+
+	c, err := client.GetChannel(channel)
+	if err != nil {
+		printErr(err)
+		return 1
+	}
+
+	fmt.Fprintf(console, "\n\tChannel\t:\t%s\n\tID\t:\t%s\n\tTab\t:\t%s\n\n", c.Title, c.Id, tab)
+
+	var t tally
+	err = c.VideosFunc(tab, func(v youtube.ChannelVideo) bool {
+		// tabs are listed newest first, so nothing older follows
+		if !after.IsZero() && !v.Published.IsZero() && v.Published.Before(after) {
+			return false
+		}
+
+		if archived(v.Id, s.option) {
+			fmt.Fprintf(console, "[%s] Skipping archived video: %s\n", v.Id, v.Title)
+			t.skip(v.Id, "archived")
+			return true
+		}
+
+		fmt.Fprintf(console, "[%s] %s\n", v.Id, v.Title)
+
+		video, err := client.Get(v.Id)
+		if err != nil {
+			printErr(err)
+			t.fail(v.Id, nil, err)
+			return true
+		}
+
+		// the listed publish time is approximate, check the exact date
+		if !after.IsZero() && !video.PublishDate.IsZero() && video.PublishDate.Before(after) {
+			fmt.Fprintf(console, "Skipping video published on %s\n", video.PublishDate.Format("2006-01-02"))
+			t.skip(v.Id, "published before "+after.Format("2006-01-02"))
+			return true
+		}
+
+		if err := downloadFetched(&video, nil, s); err != nil {
+			printErr(err)
+			t.fail(v.Id, &video, err)
+			return true
+		}
+		t.ok(v.Id, &video)
+		return true
+	})
+	if err != nil {
+		printErr(err)
+		t.fail(channel, nil, err)
+	}
+
+	t.print("Channel")
+	return len(t.failed)
+}
+
+// ytdownload channel URL
+func runChannel(args []string) int {
+	fs := newFlagSet("channel", "URL")
+	df := addDownloadFlags(fs)
+	tab := fs.String("tab", youtube.CHANNEL_VIDEOS, "Channel tab to download: videos, shorts, streams")
+	after := fs.String("after", "", "Only download videos published on or after this date (YYYY-MM-DD, UTC)")
+	parseFlags(fs, args)
+
+	channel := oneArg(fs)
+	since, err := parseDate(*after)
+	if err != nil {
+		printErr(err)
+		return exitUsage
+	}
+	return exitCode(downloadChannel(channel, *tab, since, df.settings()))
+}
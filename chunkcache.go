@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// size of the byte ranges media is fetched and cached in
+const chunkSize = 1 << 20
+
+// a disk cache of media chunks, evicting the least recently used chunks
+// when it grows over its maximum size
+type chunkCache struct {
+	dir string
+	max int64
+
+	mu   sync.Mutex
+	size int64
+
+	// chunks being fetched, so that concurrent requests share a fetch
+	inflight map[string]*chunkFetch
+}
+
+type chunkFetch struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// open a chunk cache in dir, counting the chunks already in it
+func newChunkCache(dir string, max int64) (*chunkCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Unable to create directory %q: %s", dir, err)
+	}
+
+	c := &chunkCache{dir: dir, max: max, inflight: make(map[string]*chunkFetch)}
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			c.size += info.Size()
+		}
+		return nil
+	})
+	return c, nil
+}
+
+// the file of a chunk
+func (c *chunkCache) path(key string, index int64) string {
+	return filepath.Join(c.dir, key, fmt.Sprintf("%08d", index))
+}
+
+// Returns a chunk from the cache, or fetches and caches it. The key names
+// the stream, eg: VIDEO_ID-ITAG.
+func (c *chunkCache) get(key string, index int64, fetch func() ([]byte, error)) ([]byte, error) {
+	path := c.path(key, index)
+	if data, err := ioutil.ReadFile(path); err == nil {
+		// mark as recently used
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return data, nil
+	}
+
+	c.mu.Lock()
+	if f, ok := c.inflight[path]; ok {
+		c.mu.Unlock()
+		<-f.done
+		return f.data, f.err
+	}
+	f := &chunkFetch{done: make(chan struct{})}
+	c.inflight[path] = f
+	c.mu.Unlock()
+
+	f.data, f.err = fetch()
+	if f.err == nil {
+		c.put(path, f.data)
+	}
+
+	c.mu.Lock()
+	delete(c.inflight, path)
+	c.mu.Unlock()
+	close(f.done)
+	return f.data, f.err
+}
+
+// write a chunk and evict old ones if the cache is full
+func (c *chunkCache) put(path string, data []byte) {
+	if c.max <= 0 {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size += int64(len(data))
+	if c.size > c.max {
+		c.evict()
+	}
+}
+
+// remove the least recently used chunks until the cache is within 90% of
+// its maximum size
+func (c *chunkCache) evict() {
+	type chunk struct {
+		path  string
+		size  int64
+		mtime time.Time
+	}
+	var chunks []chunk
+	filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			chunks = append(chunks, chunk{path, info.Size(), info.ModTime()})
+		}
+		return nil
+	})
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].mtime.Before(chunks[j].mtime)
+	})
+
+	for _, ch := range chunks {
+		if c.size <= c.max/10*9 {
+			break
+		}
+		if os.Remove(ch.path) == nil {
+			c.size -= ch.size
+			// remove the stream's directory once it's empty
+			os.Remove(filepath.Dir(ch.path))
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// prefix of the environment variables that override flag defaults,
+// eg: YTDOWNLOAD_LIMIT_RATE for -limit-rate
+const envPrefix = "YTDOWNLOAD_"
+
+// a configuration file. Settings are flag names (without the dash) mapped
+// to values, eg: {"format": "mp4", "limit-rate": "2M", "post-processors": ["mp3"]}.
+// The default settings apply to every run, and a named profile selected
+// with -profile overrides them.
+type config struct {
+	Default  map[string]interface{}            `json:"default"`
+	Profiles map[string]map[string]interface{} `json:"profiles"`
+}
+
+// flags given before the command, which config values don't override
+var explicit = make(map[string]bool)
+
+// the default config file path, eg: ~/.config/ytdownload/config.json
+func configPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ytdownload", "config.json")
+}
+
+// load the config file. A missing file is an error only if it was named
+// with -config or YTDOWNLOAD_CONFIG.
+func loadConfig(filename string) (config, error) {
+	var c config
+
+	named := filename != ""
+	if !named {
+		if filename = configPath(); filename == "" {
+			return c, nil
+		}
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) && !named {
+			return c, nil
+		}
+		return c, fmt.Errorf("Unable to read config %q: %s", filename, err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("Invalid config %q: %s", filename, err)
+	}
+	return c, nil
+}
+
+// the environment variable for a flag
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flag, "-", "_", -1))
+}
+
+// set the flags not given on the command line from the environment, the
+// selected profile and the config file's defaults, in that order
+func applyConfig(fs *flag.FlagSet) error {
+	set := make(map[string]bool)
+	for name := range explicit {
+		set[name] = true
+	}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	// the config file and profile themselves
+	for _, name := range []string{"config", "profile"} {
+		if v, ok := os.LookupEnv(envName(name)); ok && !set[name] {
+			fs.Set(name, v)
+		}
+	}
+
+	c, err := loadConfig(global.config)
+	if err != nil {
+		return err
+	}
+	profile := c.Default
+	if global.profile != "" {
+		p, ok := c.Profiles[global.profile]
+		if !ok {
+			return fmt.Errorf("Unknown profile %q", global.profile)
+		}
+		profile = merge(c.Default, p)
+	}
+
+	var errs []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || f.Name == "config" || f.Name == "profile" {
+			return
+		}
+
+		from := "$" + envName(f.Name)
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			v, found := profile[f.Name]
+			if !found {
+				return
+			}
+			if value, err = configValue(v); err != nil {
+				errs = append(errs, fmt.Sprintf("Invalid config value for %q: %s", f.Name, err))
+				return
+			}
+			from = "config"
+		}
+
+		if err := fs.Set(f.Name, value); err != nil {
+			errs = append(errs, fmt.Sprintf("Invalid value %q for -%s from %s: %s", value, f.Name, from, err))
+		}
+	})
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// a config value as a flag value. Lists are joined with commas.
+func configValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("unsupported type %T", v)
+}
+
+// merge settings, the latter overriding the former
+func merge(a, b map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(a)+len(b))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// the web UI, a single page using the API
+//
+//go:embed web/index.html
+var webUI []byte
+
+// the REST API and web UI of the daemon command
+type daemon struct {
+	queue   *queue
+	metrics *metrics
+}
+
+// an enqueue request
+type enqueueRequest struct {
+	URL string `json:"url"`
+	jobOptions
+}
+
+// the default queue file, next to the config file
+func queuePath() string {
+	if path := configPath(); path != "" {
+		return filepath.Join(filepath.Dir(path), "queue.json")
+	}
+	return "ytdownload-queue.json"
+}
+
+// ytdownload daemon
+func runDaemon(args []string) int {
+	fs := newFlagSet("daemon", "")
+	addr := fs.String("addr", "localhost:8090", "Address to listen on, eg: :8090 for all interfaces")
+	file := fs.String("queue", queuePath(), "File the download queue is kept in")
+	concurrency := fs.Int("concurrency", 2, "Number of parallel downloads")
+	parseFlags(fs, args)
+
+	q, err := loadQueue(*file)
+	if err != nil {
+		printErr(err)
+		return exitFailed
+	}
+	d := &daemon{queue: q, metrics: newMetrics()}
+	client.SetMetrics(d.metrics)
+	done := q.start(*concurrency)
+
+	srv := &http.Server{Addr: *addr, Handler: d}
+	failed := make(chan error, 1)
+	go func() {
+		failed <- srv.ListenAndServe()
+	}()
+	fmt.Fprintf(console, "Listening on http://%s/\n", *addr)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	code := exitOK
+	select {
+	case <-ctx.Done():
+	case err := <-failed:
+		printErr(err)
+		code = exitFailed
+	}
+
+	// stop the downloads, which resume on the next start, and close the
+	// event streams before shutting down
+	q.close()
+	<-done
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv.Shutdown(shutdown)
+	return code
+}
+
+// routes:
+//
+//	GET    /                 web UI
+//	GET    /info?url=URL     a video's metadata
+//	GET    /jobs             list jobs
+//	POST   /jobs             enqueue {"url": "...", "format": "mp4", ...}
+//	GET    /jobs/ID          job status and progress
+//	DELETE /jobs/ID          cancel and remove a job
+//	POST   /jobs/ID/cancel   cancel a job
+//	POST   /jobs/ID/retry    queue a failed or cancelled job again
+//	GET    /events           server-sent events of job updates
+//	GET    /metrics          Prometheus metrics
+func (d *daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	route := r.Method + " " + parts[0]
+	switch len(parts) {
+	case 1:
+	case 2:
+		route += "/ID"
+	case 3:
+		route += "/ID/" + parts[2]
+	default:
+		route = ""
+	}
+
+	// browsers send the Origin of cross-site requests, which pages the
+	// user visits could use to queue downloads
+	if r.Method != "GET" && r.Method != "HEAD" && !sameOrigin(r) {
+		writeError(w, http.StatusForbidden, "Cross-origin requests aren't allowed")
+		return
+	}
+
+	var (
+		j   job
+		err error
+	)
+	switch route {
+	case "GET ":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(webUI)
+		return
+	case "GET info":
+		d.info(w, r)
+		return
+	case "GET jobs":
+		writeJSON(w, http.StatusOK, d.queue.list())
+		return
+	case "POST jobs":
+		d.enqueue(w, r)
+		return
+	case "GET events":
+		d.events(w, r)
+		return
+	case "GET metrics":
+		d.metrics.ServeHTTP(w, r)
+		return
+	case "GET jobs/ID":
+		var ok bool
+		if j, ok = d.queue.get(parts[1]); !ok {
+			err = errNoJob
+		}
+	case "DELETE jobs/ID":
+		if err = d.queue.remove(parts[1]); err == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	case "POST jobs/ID/cancel":
+		j, err = d.queue.cancel(parts[1])
+	case "POST jobs/ID/retry":
+		j, err = d.queue.retry(parts[1])
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, j)
+	case errNoJob:
+		writeError(w, http.StatusNotFound, err.Error())
+	case errJobStatus:
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// fetch the metadata of the video in the url parameter
+func (d *daemon) info(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("url")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "Missing url")
+		return
+	}
+	video, err := client.GetCached(id)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, video)
+}
+
+// check that a request comes from the daemon's own pages or a client
+// other than a browser, which doesn't send an Origin
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// add a job from a JSON request
+func (d *daemon) enqueue(w http.ResponseWriter, r *http.Request) {
+	// forms can't send JSON, only scripts with a CORS preflight can
+	if t, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || t != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+
+	var req enqueueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return
+	}
+	j, err := d.queue.add(req.URL, req.jobOptions)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	printVerbose("[%s] Queued %s", j.ID, j.URL)
+	writeJSON(w, http.StatusCreated, j)
+}
+
+// stream job updates as server-sent events, eg:
+//
+//	event: progress
+//	data: {"id": "...", "status": "running", "progress": {...}, ...}
+func (d *daemon) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "Streaming isn't supported")
+		return
+	}
+
+	ch := d.queue.subscribe()
+	defer d.queue.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e.Job)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		printErr(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	youtube "github.com/knadh/go-get-youtube/youtube"
+)
+
+// download settings
+type settings struct {
+	itag            int
+	format          string // format selector
+	option          *youtube.Option
+	thumbnail       string
+	thumbnailFormat string
+	writeInfo       bool
+	output          string // output filename template
+	liveFromStart   bool
+	waitUpcoming    bool
+}
+
+// flags shared by the commands that download videos
+type downloadFlags struct {
+	itag            *int
+	format          *string
+	limitRate       *string
+	postProcessors  *string
+	resume          *bool
+	rename          *bool
+	mp3             *bool
+	thumbnail       *string
+	thumbnailFormat *string
+	writeInfo       *bool
+	output          *string
+	archive         *string
+	json            *bool
+	liveFromStart   *bool
+	waitUpcoming    *bool
+}
+
+// add the download flags to a command's flag set
+func addDownloadFlags(fs *flag.FlagSet) *downloadFlags {
+	return &downloadFlags{
+		itag:            fs.Int("itag", 0, "Select video format by Itag number (default best)"),
+		format:          fs.String("format", "", "Select video format: best, worst, an Itag number, a quality (hd720) or an extension (mp4)"),
+		limitRate:       fs.String("limit-rate", "", "Maximum download speed in bytes per second, eg: 500K, 2M"),
+		postProcessors:  fs.String("post-processors", "", "Comma separated post-processors to run after downloading: mp3"),
+		resume:          fs.Bool("resume", false, "Resume cancelled download"),
+		rename:          fs.Bool("rename", false, "Rename downloaded file using video title"),
+		mp3:             fs.Bool("mp3", false, "Extract MP3 audio using ffmpeg"),
+		thumbnail:       fs.String("thumbnail", "", "Download thumbnail of size: best, maxres, sd, hq, mq, default"),
+		thumbnailFormat: fs.String("thumbnail-format", "jpg", "Thumbnail image format: jpg, png"),
+		writeInfo:       fs.Bool("write-info-json", false, "Write video metadata to a .info.json file next to the download"),
+		output:          fs.String("o", "", "Output filename template, eg: \"%(uploader)s/%(title)s [%(id)s].%(ext)s\" (default \""+youtube.DEFAULT_TEMPLATE+"\")"),
+		archive:         fs.String("download-archive", "", "Skip videos listed in this file, and record downloaded videos in it"),
+		json:            fs.Bool("json", false, "Print download results as JSON on stdout"),
+		liveFromStart:   fs.Bool("live-from-start", false, "Record live streams from the start of their DVR window instead of from now"),
+		waitUpcoming:    fs.Bool("wait-for-video", false, "Wait for upcoming live streams and premieres to start"),
+	}
+}
+
+// the download settings for parsed flags
+func (f *downloadFlags) settings() settings {
+	// keep stdout for JSON
+	if *f.json {
+		jsonMode = true
+		console = os.Stderr
+	}
+
+	option := &youtube.Option{
+		Resume: *f.resume,
+		Rename: *f.rename,
+		Mp3:    *f.mp3,
+		Quiet:  jsonMode || global.quiet,
+	}
+	if *f.archive != "" {
+		option.Archive = youtube.NewFileArchive(*f.archive)
+	}
+
+	if *f.limitRate != "" {
+		rate, err := parseSize(*f.limitRate)
+		if err != nil || rate == 0 {
+			printErr("Invalid download speed:", *f.limitRate)
+			exit(exitUsage)
+		}
+		option.RateLimit = rate
+	}
+
+	for _, p := range strings.Split(*f.postProcessors, ",") {
+		switch strings.TrimSpace(p) {
+		case "":
+		case "mp3":
+			option.Mp3 = true
+		default:
+			printErr("Unknown post-processor:", p)
+			exit(exitUsage)
+		}
+	}
+
+	if err := checkThumbnailFormat(*f.thumbnailFormat); err != nil {
+		printErr(err)
+		exit(exitUsage)
+	}
+
+	return settings{
+		itag:            *f.itag,
+		format:          *f.format,
+		option:          option,
+		thumbnail:       *f.thumbnail,
+		thumbnailFormat: *f.thumbnailFormat,
+		writeInfo:       *f.writeInfo,
+		output:          *f.output,
+		liveFromStart:   *f.liveFromStart,
+		waitUpcoming:    *f.waitUpcoming,
+	}
+}
+
+// check if a video is recorded in the download archive, if any
+func archived(id string, option *youtube.Option) bool {
+	if option.Archive == nil {
+		return false
+	}
+	ok, err := option.Archive.Has(id)
+	if err != nil {
+		printErr(err)
+		return false
+	}
+	return ok
+}
+
+// pick the format requested by Itag or selector, or the best format
+func pickFormat(video *youtube.Video, s settings) (int, error) {
+	if s.itag > 0 {
+		return video.SelectFormat(strconv.Itoa(s.itag))
+	}
+	return video.SelectFormat(s.format)
+}
+
+// parse a size such as 500K or 1.5G in bytes, eg: a download speed in
+// bytes per second
+func parseSize(size string) (int64, error) {
+	if size == "" {
+		return 0, errors.New("Invalid size")
+	}
+	mult := 1.0
+	switch strings.ToUpper(size[len(size)-1:]) {
+	case "K":
+		mult = youtube.KB
+	case "M":
+		mult = youtube.MB
+	case "G":
+		mult = youtube.GB
+	}
+	num := size
+	if mult > 1 {
+		num = size[:len(size)-1]
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("Invalid size: " + size)
+	}
+	return int64(n * mult), nil
+}
+
+// render the output filename of a video in the chosen format, with extra
+// template fields
+func outputFilename(video *youtube.Video, index int, vars map[string]interface{}, s settings) (string, error) {
+	tmpl := s.output
+	if tmpl == "" {
+		tmpl = youtube.DEFAULT_TEMPLATE
+	}
+	filename, err := youtube.RenderFilenameWith(video, &video.Formats[index], tmpl, vars)
+	return inDir(filename), err
+}
+
+// render the output filename of a file other than the video, such as
+// captions, with the given extension
+func renderOutput(video *youtube.Video, tmpl, ext string) (string, error) {
+	if tmpl == "" {
+		tmpl = youtube.DEFAULT_TEMPLATE
+	}
+	filename, err := youtube.RenderFilenameWith(video, nil, tmpl, map[string]interface{}{"ext": ext})
+	return inDir(filename), err
+}
+
+// download a video by id non-interactively
+func downloadEntry(id string, vars map[string]interface{}, s settings) (*youtube.Video, error) {
+	video, err := client.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &video, downloadFetched(&video, vars, s)
+}
+
+// download a video with fetched metadata non-interactively
+func downloadFetched(video *youtube.Video, vars map[string]interface{}, s settings) error {
+	if isLive(video) {
+		return downloadLive(video, vars, s)
+	}
+
+	index, err := pickFormat(video, s)
+	if err != nil {
+		return err
+	}
+
+	filename, err := outputFilename(video, index, vars, s)
+	if err != nil {
+		return err
+	}
+
+	if s.thumbnail != "" {
+		downloadThumbnail(*video, s.thumbnail, s.thumbnailFormat, filename)
+	}
+
+	if err := downloadVideo(video, index, filename, s.option); err != nil {
+		return err
+	}
+
+	if s.writeInfo {
+		return writeInfo(video)
+	}
+	return nil
+}
+
+func writeInfo(video *youtube.Video) error {
+	filename := strings.TrimSuffix(video.Filename, filepath.Ext(video.Filename)) + ".info.json"
+	err := video.WriteInfoJSON(filename)
+	if err != nil {
+		printErr(err)
+	} else {
+		fmt.Fprintln(console, "Wrote metadata:", filename)
+	}
+	return err
+}
+
+func downloadVideo(video *youtube.Video, index int, filename string, option *youtube.Option) error {
+
+	fmt.Fprintf(console, "Downloading to '%s'\n", filename)
+	printVerbose("Source: %s", video.Formats[index].Url)
+
+	err := video.Download(index, filename, option)
+	if err != nil {
+		printErr(err)
+		printErr("Unable to download video content from Youtube.")
+	} else {
+		fmt.Fprintln(console, "Downloaded video:", video.Filename)
+	}
+	return err
+}
+
+func getItag(max int) (i int) {
+	for {
+		fmt.Fprintf(console, "Pick a format [0-%d]: ", max)
+		if _, err := fmt.Scanf("%d", &i); err == nil {
+			if i >= 0 && i <= max {
+				return
+			}
+		}
+		fmt.Fprintln(console, "Invalid entry:", i)
+	}
+}
+
+// ytdownload get URL...
+//
+// Without a command, the flat flags of older versions (-id, -playlist,
+// -channel, -batch-file) are handled here too.
+func runGet(args []string) int {
+	fs := newFlagSet("get", "URL...")
+	df := addDownloadFlags(fs)
+	video_id := fs.String("id", "", "Youtube video ID to download")
+	no_interactive := fs.Bool("no-interactive", false, "Pick the best format instead of asking, if no -itag is given")
+	list_formats := fs.Bool("list-formats", false, "List the video's formats and exit")
+	dump_json := fs.Bool("dump-json", false, "Print the video's metadata as JSON and exit")
+	batch_file := fs.String("batch-file", "", "Download video IDs or URLs listed in this file, one per line (- for stdin)")
+	concurrency := fs.Int("concurrency", 1, "Number of parallel downloads with several URLs or a batch file")
+	playlist_id := fs.String("playlist", "", "Youtube playlist ID or URL to download (same as the playlist command)")
+	playlist_items := fs.String("playlist-items", "", "Playlist items to download, eg: 1-10,15 (default all)")
+	channel := fs.String("channel", "", "Youtube channel ID, @handle or URL to download (same as the channel command)")
+	channel_tab := fs.String("channel-tab", youtube.CHANNEL_VIDEOS, "Channel tab to download: videos, shorts, streams")
+	after := fs.String("after", "", "Only download channel videos published on or after this date (YYYY-MM-DD, UTC)")
+	parseFlags(fs, args)
+
+	if *dump_json {
+		*df.json = true
+	}
+	s := df.settings()
+
+	switch {
+	case *batch_file != "":
+		return exitCode(downloadBatch(*batch_file, fs.Args(), *concurrency, s))
+	case *playlist_id != "":
+		return exitCode(downloadPlaylist(*playlist_id, *playlist_items, s))
+	case *channel != "":
+		since, err := parseDate(*after)
+		if err != nil {
+			printErr(err)
+			return exitUsage
+		}
+		return exitCode(downloadChannel(*channel, *channel_tab, since, s))
+	case *video_id == "" && fs.NArg() == 0:
+		fs.Usage()
+		return exitUsage
+	case fs.NArg() > 1 || (*video_id != "" && fs.NArg() > 0):
+		// several videos
+		items := fs.Args()
+		if *video_id != "" {
+			items = append([]string{*video_id}, items...)
+		}
+		return exitCode(downloadBatch("", items, *concurrency, s))
+	}
+
+	id := *video_id
+	if id == "" {
+		id = fs.Arg(0)
+	}
+	if archived(youtube.VideoId(id), s.option) {
+		fmt.Fprintln(console, "Video is already in the download archive.")
+		return exitOK
+	}
+
+	// fetch the video metadata
+	var t tally
+	video, err := fetchVideo(id, true)
+	if err != nil {
+		t.fail(id, nil, err)
+		return exitFailed
+	}
+
+	switch {
+	case *dump_json:
+		return dumpJSON(video)
+	case *list_formats && jsonMode:
+		printJSON(video.Formats)
+		return exitOK
+	case *list_formats:
+		printFormats(video)
+		return exitOK
+	}
+
+	printVideoMeta(video)
+
+	if isLive(&video) {
+		if err := downloadLive(&video, nil, s); err != nil {
+			t.fail(id, &video, err)
+			return exitFailed
+		}
+		t.ok(id, &video)
+		return exitOK
+	}
+
+	// get the format choice from the user
+	var index int
+	if s.itag > 0 || s.format != "" || *no_interactive || jsonMode {
+		if index, err = pickFormat(&video, s); err != nil {
+			printErr(err)
+			t.fail(id, &video, err)
+			return exitFailed
+		}
+		format := video.Formats[index]
+		fmt.Fprintf(console, "Selected: %s %s\n", format.Video_type, format.Quality)
+	} else {
+		max := len(video.Formats) - 1
+		index = getItag(max)
+	}
+
+	filename, err := outputFilename(&video, index, nil, s)
+	if err != nil {
+		printErr(err)
+		t.fail(id, &video, err)
+		return exitFailed
+	}
+
+	if s.thumbnail != "" {
+		downloadThumbnail(video, s.thumbnail, s.thumbnailFormat, filename)
+	}
+
+	err = downloadVideo(&video, index, filename, s.option)
+	if err == nil && s.writeInfo {
+		err = writeInfo(&video)
+	}
+	if err != nil {
+		t.fail(id, &video, err)
+		return exitFailed
+	}
+	t.ok(id, &video)
+	return exitOK
+}
+
+// parse a YYYY-MM-DD date as midnight UTC, like the publish dates in video
+// metadata. An empty date is the zero time.
+func parseDate(date string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return t, errors.New("Invalid date: " + date)
+	}
+	return t, nil
+}
+
+// the exit code for a number of failed downloads
+func exitCode(failed int) int {
+	if failed > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
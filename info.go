@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	youtube "github.com/knadh/go-get-youtube/youtube"
+)
+
+func printVideoMeta(video youtube.Video) {
+	txt :=
+		`
+	ID	:	%s
+	Title	:	%s
+	Author	:	%s
+	Views	:	%d
+	Rating	:	%f
+	Category:	%s
+	Published:	%s`
+
+	published := ""
+	if !video.PublishDate.IsZero() {
+		published = video.PublishDate.Format("2006-01-02")
+	}
+	fmt.Fprintf(console, txt, video.Id, video.Title, video.Author, video.View_count, video.Avg_rating, video.Category, published)
+	fmt.Fprintln(console)
+
+	switch {
+	case video.IsUpcoming && !video.ScheduledStart.IsZero():
+		fmt.Fprintf(console, "\tLive\t:\tstarts at %s\n", video.ScheduledStart.Local().Format("2006-01-02 15:04"))
+	case video.IsUpcoming:
+		fmt.Fprintln(console, "\tLive\t:\tupcoming")
+	case video.IsLive:
+		fmt.Fprintln(console, "\tLive\t:\tnow")
+	}
+
+	if global.verbose {
+		fmt.Fprintf(console, "\tKeywords:\t%s\n\tChannel\t:\t%s (%s)\n\tLength\t:\t%ds\n",
+			video.Keywords, video.OwnerChannelName, video.ChannelID, video.Length_seconds)
+		if len(video.Chapters) > 0 {
+			fmt.Fprintln(console, "\n\tChapters")
+			for _, c := range video.Chapters {
+				fmt.Fprintf(console, "\t%d\t%s\n", c.Start, c.Title)
+			}
+		}
+		fmt.Fprintf(console, "\n\t%s\n", strings.Replace(video.Description, "\n", "\n\t", -1))
+	}
+
+	printFormats(video)
+}
+
+func printFormats(video youtube.Video) {
+	fmt.Fprintln(console, "\n\tFormats")
+
+	for i := 0; i < len(video.Formats); i++ {
+		fmt.Fprintf(console, "\t%d\tItag %d: %s\t%s\n", i, video.Formats[i].Itag, video.Formats[i].Quality, video.Formats[i].Video_type)
+		printVerbose("\t\t%s", video.Formats[i].Url)
+	}
+
+	if global.verbose && len(video.AdaptiveFormats) > 0 {
+		fmt.Fprintln(console, "\n\tAdaptive formats")
+		for _, f := range video.AdaptiveFormats {
+			fmt.Fprintf(console, "\t\tItag %d: %s\t%s\t%d kbps\n", f.Itag, f.QualityLabel, f.Video_type, f.Bitrate/1000)
+		}
+	}
+	fmt.Fprintln(console)
+	fmt.Fprintln(console)
+}
+
+// fetch a video's metadata for a command. Unless the format URLs are
+// needed, cached metadata with expired URLs will do.
+func fetchVideo(id string, needURLs bool) (youtube.Video, error) {
+	fmt.Fprintln(console, "Hold on ...")
+	get := client.GetCached
+	if needURLs {
+		get = client.Get
+	}
+	video, err := get(id)
+	if err != nil {
+		printErr(err)
+	}
+	return video, err
+}
+
+// print a value as indented JSON on stdout
+func dumpJSON(v interface{}) int {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(v); err != nil {
+		printErr(err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+// ytdownload info URL
+func runInfo(args []string) int {
+	fs := newFlagSet("info", "URL")
+	asJSON := fs.Bool("json", false, "Print the metadata as JSON")
+	parseFlags(fs, args)
+
+	if *asJSON {
+		console = os.Stderr
+	}
+
+	video, err := fetchVideo(oneArg(fs), *asJSON || global.verbose)
+	if err != nil {
+		return exitFailed
+	}
+
+	if *asJSON {
+		return dumpJSON(video)
+	}
+
+	// the metadata is the output, even in quiet mode
+	console = os.Stdout
+	printVideoMeta(video)
+	return exitOK
+}
+
+// ytdownload formats URL
+func runFormats(args []string) int {
+	fs := newFlagSet("formats", "URL")
+	asJSON := fs.Bool("json", false, "Print the formats as JSON")
+	dash := fs.Bool("dash", false, "Print a DASH manifest (MPD) of the adaptive formats")
+	parseFlags(fs, args)
+
+	if *asJSON || *dash {
+		console = os.Stderr
+	}
+
+	video, err := fetchVideo(oneArg(fs), *asJSON || *dash || global.verbose)
+	if err != nil {
+		return exitFailed
+	}
+
+	switch {
+	case *dash:
+		mpd, err := video.DASHManifest()
+		if err != nil {
+			printErr(err)
+			return exitFailed
+		}
+		os.Stdout.Write(mpd)
+		return exitOK
+	case *asJSON:
+		return dumpJSON(video.Formats)
+	}
+
+	console = os.Stdout
+	printFormats(video)
+	return exitOK
+}
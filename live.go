@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	youtube "github.com/knadh/go-get-youtube/youtube"
+)
+
+// check if a video is recorded from its live stream manifest instead of
+// downloaded
+func isLive(video *youtube.Video) bool {
+	if video.IsLive || video.IsUpcoming {
+		return true
+	}
+	return len(video.Formats) == 0 && (video.HLSManifestURL != "" || video.DASHManifestURL != "")
+}
+
+// a context that is cancelled on interrupt or termination, so that
+// recordings stop cleanly
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sig:
+			fmt.Fprintln(console, "\nStopping ...")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sig)
+		cancel()
+	}
+}
+
+// record a live stream or premiere, with extra template fields
+func downloadLive(video *youtube.Video, vars map[string]interface{}, s settings) error {
+	if vars == nil {
+		vars = make(map[string]interface{})
+	}
+	vars["ext"] = video.LiveExtension()
+
+	tmpl := s.output
+	if tmpl == "" {
+		tmpl = youtube.DEFAULT_TEMPLATE
+	}
+	filename, err := youtube.RenderFilenameWith(video, nil, tmpl, vars)
+	if err != nil {
+		return err
+	}
+	filename = inDir(filename)
+
+	if s.thumbnail != "" {
+		downloadThumbnail(*video, s.thumbnail, s.thumbnailFormat, filename)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	option := &youtube.LiveOption{
+		Option:       *s.option,
+		FromStart:    s.liveFromStart,
+		WaitUpcoming: s.waitUpcoming,
+	}
+	fmt.Fprintf(console, "Recording live stream to '%s' (Ctrl+C to stop)\n", filename)
+	if err := video.RecordLive(ctx, filename, option); err != nil {
+		printErr(err)
+		return err
+	}
+	fmt.Fprintln(console, "Recorded stream:", video.Filename)
+
+	if s.writeInfo {
+		return writeInfo(video)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the metrics of the serve and daemon commands, in the Prometheus text
+// exposition format
+type metrics struct {
+	mu sync.Mutex
+
+	metadataRequests *counterVec
+	downloads        *counterVec
+	bytes            *counterVec
+	retries          *counterVec
+	refreshes        *counterVec
+	active           float64
+	duration         *histogramVec
+	throughput       *histogramVec
+	postProcessors   *histogramVec
+}
+
+// a counter with a label, or none if the label is ""
+type counterVec struct {
+	name, help, label string
+	values            map[string]float64
+}
+
+// a histogram with a label, or none if the label is ""
+type histogramVec struct {
+	name, help, label string
+	buckets           []float64
+	series            map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // by bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func newMetrics() *metrics {
+	return &metrics{
+		metadataRequests: newCounterVec("ytdownload_metadata_requests_total", "Metadata requests by outcome: playability status or error.", "outcome"),
+		downloads:        newCounterVec("ytdownload_downloads_total", "Finished downloads and live recordings by outcome.", "outcome"),
+		bytes:            newCounterVec("ytdownload_downloaded_bytes_total", "Bytes downloaded.", ""),
+		retries:          newCounterVec("ytdownload_retries_total", "Retried requests by kind: proxy or segment.", "kind"),
+		refreshes:        newCounterVec("ytdownload_url_refreshes_total", "Format URL refreshes.", ""),
+		duration: newHistogramVec("ytdownload_download_duration_seconds", "Duration of downloads.", "",
+			[]float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600}),
+		throughput: newHistogramVec("ytdownload_download_throughput_bytes_per_second", "Average speed of downloads.", "",
+			[]float64{64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20, 64 << 20}),
+		postProcessors: newHistogramVec("ytdownload_post_processor_duration_seconds", "Duration of post-processors by name: mp3 or merge.", "post_processor",
+			[]float64{0.5, 1, 5, 15, 60, 300}),
+	}
+}
+
+func newCounterVec(name, help, label string) *counterVec {
+	return &counterVec{name: name, help: help, label: label, values: make(map[string]float64)}
+}
+
+func newHistogramVec(name, help, label string, buckets []float64) *histogramVec {
+	return &histogramVec{name: name, help: help, label: label, buckets: buckets, series: make(map[string]*histogram)}
+}
+
+func (m *metrics) MetadataRequest(outcome string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.metadataRequests.values[outcome]++
+}
+
+func (m *metrics) DownloadStarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active++
+}
+
+func (m *metrics) DownloadFinished(bytes int64, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active--
+	m.bytes.values[""] += float64(bytes)
+
+	switch {
+	case err == context.Canceled:
+		m.downloads.values["cancelled"]++
+	case err != nil:
+		m.downloads.values["error"]++
+	default:
+		m.downloads.values["ok"]++
+		m.duration.observe("", duration.Seconds())
+		if duration > 0 {
+			m.throughput.observe("", float64(bytes)/duration.Seconds())
+		}
+	}
+}
+
+func (m *metrics) Retry(kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries.values[kind]++
+}
+
+func (m *metrics) URLRefreshed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshes.values[""]++
+}
+
+func (m *metrics) PostProcessed(name string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.postProcessors.observe(name, duration.Seconds())
+}
+
+// serve the metrics in the text exposition format
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.metadataRequests.write(w)
+	m.downloads.write(w)
+	m.bytes.write(w)
+	m.retries.write(w)
+	m.refreshes.write(w)
+	fmt.Fprintf(w, "# HELP ytdownload_active_downloads Downloads and live recordings in progress.\n")
+	fmt.Fprintf(w, "# TYPE ytdownload_active_downloads gauge\n")
+	fmt.Fprintf(w, "ytdownload_active_downloads %s\n", number(m.active))
+	m.duration.write(w)
+	m.throughput.write(w)
+	m.postProcessors.write(w)
+}
+
+// format a series' labels, eg: {outcome="OK",le="1"}
+func labels(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i < len(pairs); i += 2 {
+		if pairs[i] == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `%s="%s"`, pairs[i], labelEscaper.Replace(pairs[i+1]))
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "{" + b.String() + "}"
+}
+
+// format a sample value without an exponent
+func number(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Returns the keys of a map, sorted
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]float64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*histogram:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *counterVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	if c.label == "" && len(c.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+	}
+	for _, v := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labels(c.label, v), number(c.values[v]))
+	}
+}
+
+func (h *histogramVec) observe(label string, value float64) {
+	s, ok := h.series[label]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[label] = s
+	}
+	for i, le := range h.buckets {
+		if value <= le {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	if h.label == "" && len(h.series) == 0 {
+		h.series[""] = &histogram{counts: make([]uint64, len(h.buckets))}
+	}
+	for _, v := range sortedKeys(h.series) {
+		s := h.series[v]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labels(h.label, v, "le", number(le)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labels(h.label, v, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels(h.label, v), number(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels(h.label, v), s.count)
+	}
+}
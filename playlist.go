@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parse a playlist item selection such as "1-10,15,20-". An empty
+// selection matches every item.
+func parseItems(spec string) (func(int) bool, error) {
+	if spec == "" {
+		return func(int) bool { return true }, nil
+	}
+
+	type span struct{ from, to int }
+	var spans []span
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+		from, err := strconv.Atoi(bounds[0])
+		if err != nil || from < 1 {
+			return nil, fmt.Errorf("Invalid playlist item: %q", part)
+		}
+		to := from
+		if len(bounds) == 2 {
+			if bounds[1] == "" {
+				to = int(^uint(0) >> 1)
+			} else if to, err = strconv.Atoi(bounds[1]); err != nil || to < from {
+				return nil, fmt.Errorf("Invalid playlist range: %q", part)
+			}
+		}
+		spans = append(spans, span{from, to})
+	}
+
+	return func(i int) bool {
+		for _, s := range spans {
+			if i >= s.from && i <= s.to {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// download the selected videos of a playlist and print a summary.
+// Returns the number of failed downloads.
+func downloadPlaylist(id, items string, s settings) int {
+	selected, err := parseItems(items)
+	if err != nil {
+		printErr(err)
+		return 1
+	}
+
+	fmt.Fprintln(console, "Hold on ...")
+
+	playlist, err := client.GetPlaylist(id)
+	if err != nil {
+		printErr(err)
+		return 1
+	}
+
+	fmt.Fprintf(console, "\n\tPlaylist:\t%s\n\tAuthor\t:\t%s\n\tVideos\t:\t%d\n\n", playlist.Title, playlist.Author, len(playlist.Entries))
+
+	// prefix filenames with the index, padded to the width of the largest index
+	width := len(strconv.Itoa(len(playlist.Entries)))
+	if s.output == "" {
+		s.output = fmt.Sprintf("%%(playlist_index)0%dd-%%(id)s.%%(ext)s", width)
+	}
+
+	var t tally
+	for _, e := range playlist.Entries {
+		if !selected(e.Index) {
+			continue
+		}
+
+		if !e.IsPlayable {
+			fmt.Fprintf(console, "[%d] Skipping unavailable video: %s\n", e.Index, e.Title)
+			t.skip(e.Id, "unavailable")
+			continue
+		}
+
+		if archived(e.Id, s.option) {
+			fmt.Fprintf(console, "[%d] Skipping archived video: %s\n", e.Index, e.Title)
+			t.skip(e.Id, "archived")
+			continue
+		}
+
+		fmt.Fprintf(console, "[%d] %s\n", e.Index, e.Title)
+		vars := map[string]interface{}{
+			"playlist_index": e.Index,
+			"playlist_id":    playlist.Id,
+			"playlist":       playlist.Title,
+			"playlist_count": len(playlist.Entries),
+		}
+		video, err := downloadEntry(e.Id, vars, s)
+		if err != nil {
+			printErr(err)
+			t.fail(e.Id, video, err)
+			continue
+		}
+		t.ok(e.Id, video)
+	}
+
+	t.print("Playlist")
+	return len(t.failed)
+}
+
+// ytdownload playlist URL
+func runPlaylist(args []string) int {
+	fs := newFlagSet("playlist", "URL")
+	df := addDownloadFlags(fs)
+	items := fs.String("items", "", "Playlist items to download, eg: 1-10,15 (default all)")
+	parseFlags(fs, args)
+
+	id := oneArg(fs)
+	return exitCode(downloadPlaylist(id, *items, df.settings()))
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	youtube "github.com/knadh/go-get-youtube/youtube"
+)
+
+// job statuses
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// a queued download
+type job struct {
+	ID       string           `json:"id"`
+	URL      string           `json:"url"`
+	Options  jobOptions       `json:"options"`
+	Status   string           `json:"status"`
+	Error    string           `json:"error,omitempty"`
+	Filename string           `json:"filename,omitempty"`
+	Progress youtube.Progress `json:"progress"`
+	Created  time.Time        `json:"created"`
+	Updated  time.Time        `json:"updated"`
+
+	// set while the job is running, until its run has returned
+	cancel context.CancelFunc
+}
+
+// the download options of a job
+type jobOptions struct {
+	Itag      int    `json:"itag,omitempty"`
+	Format    string `json:"format,omitempty"` // format selector
+	Output    string `json:"output,omitempty"` // output filename template
+	LimitRate string `json:"limit_rate,omitempty"`
+	Mp3       bool   `json:"mp3,omitempty"`
+	WriteInfo bool   `json:"write_info_json,omitempty"`
+}
+
+// a job update sent to event subscribers
+type event struct {
+	Type string // status, progress or deleted
+	Job  job
+}
+
+// a persistent download queue, run by a pool of workers
+type queue struct {
+	file string
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	jobs   []*job
+	subs   map[chan event]struct{}
+	closed bool
+}
+
+// load a queue from file. Jobs that were running when the daemon stopped
+// are queued again.
+func loadQueue(file string) (*queue, error) {
+	q := &queue{file: file, subs: make(map[chan event]struct{})}
+	q.cond = sync.NewCond(&q.mu)
+
+	data, err := ioutil.ReadFile(file)
+	switch {
+	case os.IsNotExist(err):
+		return q, nil
+	case err != nil:
+		return nil, fmt.Errorf("Unable to read queue %q: %s", file, err)
+	}
+	if err := json.Unmarshal(data, &q.jobs); err != nil {
+		return nil, fmt.Errorf("Unable to read queue %q: %s", file, err)
+	}
+	for _, j := range q.jobs {
+		if j.Status == jobRunning {
+			j.Status = jobQueued
+		}
+	}
+	return q, nil
+}
+
+// write the queue to its file. Call with q.mu held.
+func (q *queue) save() {
+	data, err := json.MarshalIndent(q.jobs, "", "\t")
+	if err != nil {
+		printErr(err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(q.file), 0755); err != nil {
+		printErr(err)
+		return
+	}
+	tmp := q.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		printErr(fmt.Sprintf("Unable to write queue %q: %s", q.file, err))
+		return
+	}
+	if err := os.Rename(tmp, q.file); err != nil {
+		printErr(fmt.Sprintf("Unable to write queue %q: %s", q.file, err))
+	}
+}
+
+// update a job's status, save the queue and notify subscribers. Call with
+// q.mu held.
+func (q *queue) setStatus(j *job, status string, err error) {
+	j.Status = status
+	j.Error = ""
+	if err != nil {
+		j.Error = err.Error()
+	}
+	j.Updated = time.Now()
+	q.save()
+	q.publish("status", j)
+}
+
+// send a job update to the subscribers, dropping it for slow ones. Call
+// with q.mu held.
+func (q *queue) publish(typ string, j *job) {
+	for ch := range q.subs {
+		select {
+		case ch <- event{Type: typ, Job: *j}:
+		default:
+		}
+	}
+}
+
+// Returns a channel of job updates, closed when the queue is closed
+func (q *queue) subscribe() chan event {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ch := make(chan event, 64)
+	if q.closed {
+		close(ch)
+		return ch
+	}
+	q.subs[ch] = struct{}{}
+	return ch
+}
+
+func (q *queue) unsubscribe(ch chan event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.subs[ch]; ok {
+		delete(q.subs, ch)
+		close(ch)
+	}
+}
+
+// add a download to the queue
+func (q *queue) add(url string, opts jobOptions) (job, error) {
+	if url == "" {
+		return job{}, errors.New("Missing url")
+	}
+	if opts.LimitRate != "" {
+		if _, err := parseSize(opts.LimitRate); err != nil {
+			return job{}, err
+		}
+	}
+	if err := checkOutput(opts.Output); err != nil {
+		return job{}, err
+	}
+
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return job{}, err
+	}
+	now := time.Now()
+	j := &job{
+		ID:      hex.EncodeToString(id),
+		URL:     url,
+		Options: opts,
+		Status:  jobQueued,
+		Created: now,
+		Updated: now,
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs = append(q.jobs, j)
+	q.save()
+	q.publish("status", j)
+	q.cond.Signal()
+	return *j, nil
+}
+
+// Returns a copy of every job, oldest first
+func (q *queue) list() []job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]job, len(q.jobs))
+	for i, j := range q.jobs {
+		jobs[i] = *j
+	}
+	return jobs
+}
+
+// Returns a copy of a job
+func (q *queue) get(id string) (job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if j := q.find(id); j != nil {
+		return *j, true
+	}
+	return job{}, false
+}
+
+// find a job. Call with q.mu held.
+func (q *queue) find(id string) *job {
+	for _, j := range q.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+var (
+	errNoJob     = errors.New("No such job")
+	errJobStatus = errors.New("Job can't be changed in its current status")
+)
+
+// cancel a queued or running job
+func (q *queue) cancel(id string) (job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j := q.find(id)
+	switch {
+	case j == nil:
+		return job{}, errNoJob
+	case j.Status != jobQueued && j.Status != jobRunning:
+		return *j, errJobStatus
+	}
+	if j.cancel != nil {
+		j.cancel()
+	}
+	q.setStatus(j, jobCancelled, nil)
+	return *j, nil
+}
+
+// queue a failed or cancelled job again, once a cancelled run has stopped
+func (q *queue) retry(id string) (job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j := q.find(id)
+	switch {
+	case j == nil:
+		return job{}, errNoJob
+	case j.Status != jobFailed && j.Status != jobCancelled, j.cancel != nil:
+		return *j, errJobStatus
+	}
+	q.setStatus(j, jobQueued, nil)
+	q.cond.Signal()
+	return *j, nil
+}
+
+// remove a job, cancelling it if it's running. Its files are kept.
+func (q *queue) remove(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, j := range q.jobs {
+		if j.ID != id {
+			continue
+		}
+		if j.cancel != nil {
+			j.cancel()
+		}
+		q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
+		q.save()
+		q.publish("deleted", j)
+		return nil
+	}
+	return errNoJob
+}
+
+// start n workers. Returns a channel that's closed when they have all
+// stopped, after the queue is closed.
+func (q *queue) start(n int) chan struct{} {
+	if n < 1 {
+		n = 1
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				j, ctx := q.next()
+				if j == nil {
+					return
+				}
+				q.run(ctx, j)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// wait for the next queued job and mark it running. A job whose previous
+// run hasn't returned yet is skipped. Returns nil once the queue is closed.
+func (q *queue) next() (*job, context.Context) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !q.closed {
+		for _, j := range q.jobs {
+			if j.Status != jobQueued || j.cancel != nil {
+				continue
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			j.cancel = cancel
+			q.setStatus(j, jobRunning, nil)
+			return j, ctx
+		}
+		q.cond.Wait()
+	}
+	return nil, nil
+}
+
+// download a job's video
+func (q *queue) run(ctx context.Context, j *job) {
+	q.mu.Lock()
+	url, opts, resume := j.URL, j.Options, j.Progress.Downloaded > 0
+	q.mu.Unlock()
+
+	filename, err := q.download(ctx, j, url, opts, resume)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cancelled := ctx.Err() != nil
+	j.cancel()
+	j.cancel = nil
+	if cancelled {
+		// cancelled, removed or stopped, which set the status. The job
+		// can be queued and run again now.
+		if j.Status == jobQueued {
+			q.cond.Signal()
+		}
+		return
+	}
+	j.Filename = filename
+	if err != nil {
+		q.setStatus(j, jobFailed, err)
+		return
+	}
+	q.setStatus(j, jobDone, nil)
+}
+
+// Returns the filename a job was downloaded to
+func (q *queue) download(ctx context.Context, j *job, url string, opts jobOptions, resume bool) (string, error) {
+	video, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	if isLive(&video) {
+		return "", errors.New("Live streams can't be queued")
+	}
+
+	option := &youtube.Option{Resume: resume, Mp3: opts.Mp3, Quiet: true}
+	if opts.LimitRate != "" {
+		if option.RateLimit, err = parseSize(opts.LimitRate); err != nil {
+			return "", err
+		}
+	}
+	option.Progress = func(p youtube.Progress) {
+		q.mu.Lock()
+		j.Progress = p
+		j.Updated = time.Now()
+		q.publish("progress", j)
+		q.mu.Unlock()
+	}
+
+	s := settings{itag: opts.Itag, format: opts.Format, output: opts.Output, option: option}
+	index, err := pickFormat(&video, s)
+	if err != nil {
+		return "", err
+	}
+	filename, err := outputFilename(&video, index, nil, s)
+	if err != nil {
+		return "", err
+	}
+	rel := filename
+	if global.dir != "" {
+		if rel, err = filepath.Rel(global.dir, filename); err != nil {
+			return "", errOutput
+		}
+	}
+	if err := checkOutput(rel); err != nil {
+		return "", err
+	}
+
+	printVerbose("[%s] Downloading to '%s'", j.ID, filename)
+	if err := video.DownloadContext(ctx, index, filename, option); err != nil {
+		return filename, err
+	}
+	if opts.WriteInfo {
+		name := strings.TrimSuffix(video.Filename, filepath.Ext(video.Filename)) + ".info.json"
+		if err := video.WriteInfoJSON(name); err != nil {
+			return video.Filename, err
+		}
+	}
+	return video.Filename, nil
+}
+
+var errOutput = errors.New("Output must be a relative path in the download directory")
+
+// check that a job's output template or file stays in the download
+// directory: it's relative and has no .. components
+func checkOutput(output string) error {
+	if filepath.IsAbs(output) || strings.HasPrefix(output, "/") || strings.HasPrefix(output, `\`) || filepath.VolumeName(output) != "" {
+		return errOutput
+	}
+	for _, part := range strings.FieldsFunc(output, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return errOutput
+		}
+	}
+	return nil
+}
+
+// stop the workers and close the subscriber channels. Running jobs are
+// cancelled and queued again, so that they resume on the next start.
+func (q *queue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	for _, j := range q.jobs {
+		if j.Status == jobRunning {
+			j.cancel()
+			j.Status = jobQueued
+		}
+	}
+	q.save()
+	for ch := range q.subs {
+		delete(q.subs, ch)
+		close(ch)
+	}
+	q.cond.Broadcast()
+}
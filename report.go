@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	youtube "github.com/knadh/go-get-youtube/youtube"
+)
+
+var (
+	// print results as JSON on stdout
+	jsonMode bool
+
+	// human readable messages. In JSON mode, they go to stderr.
+	console io.Writer = os.Stdout
+)
+
+// the outcome of a video download
+type outcome struct {
+	Input    string         `json:"input"`
+	Status   string         `json:"status"` // downloaded, skipped or failed
+	Reason   string         `json:"reason,omitempty"`
+	Filename string         `json:"filename,omitempty"`
+	Video    *youtube.Video `json:"video,omitempty"`
+}
+
+// collects the outcomes of a multi-video download
+type tally struct {
+	downloaded, skipped int
+	failed              []string
+}
+
+// record a successful download
+func (t *tally) ok(input string, video *youtube.Video) {
+	t.downloaded++
+	printJSON(outcome{Input: input, Status: "downloaded", Filename: video.Filename, Video: video})
+}
+
+// record a skipped video
+func (t *tally) skip(input, reason string) {
+	t.skipped++
+	printJSON(outcome{Input: input, Status: "skipped", Reason: reason})
+}
+
+// record a failed download. video may be nil.
+func (t *tally) fail(input string, video *youtube.Video, err error) {
+	t.failed = append(t.failed, fmt.Sprintf("%s: %s", input, err))
+	printJSON(outcome{Input: input, Status: "failed", Reason: err.Error(), Video: video})
+}
+
+// print a summary of the downloads
+func (t *tally) print(what string) {
+	fmt.Fprintf(console, "\n%s done: %d downloaded, %d skipped, %d failed\n", what, t.downloaded, t.skipped, len(t.failed))
+	for _, f := range t.failed {
+		fmt.Fprintln(console, "\t"+f)
+	}
+}
+
+// print a value as a line of JSON on stdout, in JSON mode
+func printJSON(v interface{}) {
+	if !jsonMode {
+		return
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	}
+}
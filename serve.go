@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	youtube "github.com/knadh/go-get-youtube/youtube"
+)
+
+const (
+	// format URLs are refreshed this long before they expire
+	refreshMargin = time.Minute
+
+	// served videos are forgotten when unused for this long, or when there
+	// are more than maxServed
+	servedTTL = time.Hour
+	maxServed = 1000
+)
+
+// the media proxy of the serve command
+type server struct {
+	cache   *chunkCache
+	metrics *metrics
+
+	mu     sync.Mutex
+	videos map[string]*servedVideo
+}
+
+// a video being served, refreshed when its format URLs expire
+type servedVideo struct {
+	mu      sync.Mutex
+	video   *youtube.Video
+	lengths map[int]int64 // content lengths by Itag, for formats without one
+
+	// last request for the video, guarded by server.mu
+	used time.Time
+}
+
+// ytdownload serve
+func runServe(args []string) int {
+	fs := newFlagSet("serve", "")
+	addr := fs.String("addr", "localhost:8080", "Address to listen on, eg: :8080 for all interfaces")
+	size := fs.String("cache-size", "1G", "Maximum size of the media cache, 0 to disable it")
+	parseFlags(fs, args)
+
+	max, err := parseSize(*size)
+	if err != nil {
+		printErr(err)
+		return exitUsage
+	}
+	cache, err := newChunkCache(cacheDir("chunks"), max)
+	if err != nil {
+		printErr(err)
+		return exitFailed
+	}
+
+	s := &server{cache: cache, metrics: newMetrics(), videos: make(map[string]*servedVideo)}
+	client.SetMetrics(s.metrics)
+	fmt.Fprintf(console, "Serving on http://%s/watch/VIDEO_ID?itag=N\n", *addr)
+	if err := http.ListenAndServe(*addr, s); err != nil {
+		printErr(err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Path == "/metrics" {
+		s.metrics.ServeHTTP(w, r)
+		return
+	}
+
+	parts := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	switch parts[0] {
+	case "watch":
+		s.serveMedia(w, r, parts[1])
+	case "dash":
+		s.serveManifest(w, r, parts[1])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serve a DASH manifest of a video's adaptive formats, streamed through
+// the server
+func (s *server) serveManifest(w http.ResponseWriter, r *http.Request, id string) {
+	sv, err := s.video(id, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	sv.mu.Lock()
+	mpd, err := sv.video.DASHManifestFunc(func(f *youtube.Format) string {
+		return "/watch/" + url.PathEscape(id) + "?itag=" + strconv.Itoa(f.Itag)
+	})
+	sv.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/dash+xml")
+	w.Write(mpd)
+}
+
+// serve a format of a video, selected with the itag or format parameters
+func (s *server) serveMedia(w http.ResponseWriter, r *http.Request, id string) {
+	itag, _ := strconv.Atoi(r.URL.Query().Get("itag"))
+	sv, err := s.video(id, itag)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	f, err := sv.format(itag, r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	length, err := sv.length(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	// the requested range
+	start, end := int64(0), length-1
+	status := http.StatusOK
+	if h := r.Header.Get("Range"); h != "" {
+		var ok bool
+		if start, end, ok = parseRange(h, length); !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", length))
+			http.Error(w, "Requested range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, length))
+	}
+
+	w.Header().Set("Content-Type", strings.TrimSpace(strings.SplitN(f.Video_type, ";", 2)[0]))
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+	if r.Method == "HEAD" {
+		w.WriteHeader(status)
+		return
+	}
+
+	key := fmt.Sprintf("%s-%d", youtube.VideoId(id), f.Itag)
+	for index := start / chunkSize; index <= end/chunkSize; index++ {
+		data, err := s.cache.get(key, index, func() ([]byte, error) {
+			return sv.fetchChunk(f.Itag, index, length)
+		})
+		if err != nil {
+			printErr(err)
+			if index == start/chunkSize {
+				w.Header().Del("Content-Length")
+				w.Header().Del("Content-Range")
+				http.Error(w, err.Error(), http.StatusBadGateway)
+			}
+			return
+		}
+
+		// the part of the chunk within the range
+		from, to := int64(0), int64(len(data))
+		if offset := index * chunkSize; offset < start {
+			from = start - offset
+		}
+		if last := index*chunkSize + int64(len(data)) - 1; last > end {
+			to -= last - end
+		}
+		if index == start/chunkSize {
+			w.WriteHeader(status)
+		}
+		if _, err := w.Write(data[from:to]); err != nil {
+			// the client went away
+			return
+		}
+	}
+}
+
+// Returns a served video, fetching its metadata if it's new or if the URL
+// of the format with itag (or any format, if 0) is about to expire
+func (s *server) video(id string, itag int) (*servedVideo, error) {
+	id = youtube.VideoId(id)
+
+	s.mu.Lock()
+	sv, ok := s.videos[id]
+	if ok {
+		sv.used = time.Now()
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		printVerbose("Fetching %s", id)
+		video, err := client.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		return s.add(id, &servedVideo{video: &video, lengths: make(map[int]int64)}), nil
+	}
+
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+
+	if sv.expiring(itag) {
+		printVerbose("Refreshing %s", id)
+		if err := sv.video.Refresh(); err != nil {
+			return nil, err
+		}
+	}
+	return sv, nil
+}
+
+// add a fetched video, unless another request added it first. Videos unused
+// for servedTTL, and the least recently used ones beyond maxServed, are
+// forgotten.
+func (s *server) add(id string, sv *servedVideo) *servedVideo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if added, ok := s.videos[id]; ok {
+		added.used = now
+		return added
+	}
+
+	for id, v := range s.videos {
+		if now.Sub(v.used) > servedTTL {
+			delete(s.videos, id)
+		}
+	}
+	for len(s.videos) >= maxServed {
+		var oldest string
+		for id, v := range s.videos {
+			if oldest == "" || v.used.Before(s.videos[oldest].used) {
+				oldest = id
+			}
+		}
+		delete(s.videos, oldest)
+	}
+
+	sv.used = now
+	s.videos[id] = sv
+	return sv
+}
+
+// check if a format URL is about to expire. Call with sv.mu held.
+func (sv *servedVideo) expiring(itag int) bool {
+	f := sv.video.FormatByItag(itag)
+	if f == nil {
+		if len(sv.video.Formats) == 0 {
+			return false
+		}
+		f = &sv.video.Formats[0]
+	}
+	expires := f.Expires()
+	return !expires.IsZero() && time.Until(expires) < refreshMargin
+}
+
+// Returns a copy of the format with itag, or else the one matching a
+// selector (eg: best, mp4)
+func (sv *servedVideo) format(itag int, selector string) (youtube.Format, error) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+
+	if itag > 0 {
+		if f := sv.video.FormatByItag(itag); f != nil {
+			return *f, nil
+		}
+		return youtube.Format{}, fmt.Errorf("Unknown Itag number: %d", itag)
+	}
+
+	index, err := sv.video.SelectFormat(selector)
+	if err != nil {
+		return youtube.Format{}, err
+	}
+	return sv.video.Formats[index], nil
+}
+
+// Returns the content length of a format, asking Youtube if it's unknown
+func (sv *servedVideo) length(f youtube.Format) (int64, error) {
+	if f.ContentLength > 0 {
+		return f.ContentLength, nil
+	}
+
+	sv.mu.Lock()
+	length, ok := sv.lengths[f.Itag]
+	sv.mu.Unlock()
+	if ok {
+		return length, nil
+	}
+
+	length, err := client.ContentLength(f.Url)
+	if err != nil {
+		return 0, err
+	}
+
+	sv.mu.Lock()
+	sv.lengths[f.Itag] = length
+	sv.mu.Unlock()
+	return length, nil
+}
+
+// fetch a chunk of a format from Youtube, refreshing its URL once if it
+// has expired
+func (sv *servedVideo) fetchChunk(itag int, index, length int64) ([]byte, error) {
+	start := index * chunkSize
+	end := start + chunkSize - 1
+	if end >= length {
+		end = length - 1
+	}
+
+	for try := 0; ; try++ {
+		sv.mu.Lock()
+		f := sv.video.FormatByItag(itag)
+		var u string
+		if f != nil {
+			u = f.Url
+		}
+		sv.mu.Unlock()
+		if u == "" {
+			return nil, fmt.Errorf("Unknown Itag number: %d", itag)
+		}
+
+		data, err := fetchRange(u, start, end)
+		if err != errForbidden || try > 0 {
+			return data, err
+		}
+
+		// the URL has expired
+		sv.mu.Lock()
+		err = sv.video.Refresh()
+		sv.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+var errForbidden = errors.New("Media request failed: 403 Forbidden")
+
+// fetch a byte range of a URL
+func fetchRange(u string, start, end int64) ([]byte, error) {
+	resp, err := client.GetRange(context.Background(), u, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("Media request failed: %s", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, errForbidden
+	case resp.StatusCode != http.StatusPartialContent && !(resp.StatusCode == http.StatusOK && start == 0):
+		return nil, fmt.Errorf("Media request failed: %s", resp.Status)
+	}
+
+	data := make([]byte, end-start+1)
+	n, err := io.ReadFull(resp.Body, data)
+	if err != nil {
+		return nil, fmt.Errorf("Media request failed after %d bytes: %s", n, err)
+	}
+	return data, nil
+}
+
+// parse a single byte range of a Range header: bytes=a-b, bytes=a- or
+// bytes=-n. Multiple ranges aren't supported and are not ok.
+func parseRange(h string, length int64) (int64, int64, bool) {
+	if !strings.HasPrefix(h, "bytes=") || strings.Contains(h, ",") {
+		return 0, 0, false
+	}
+	bounds := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(h, "bytes=")), "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, false
+	}
+
+	if bounds[0] == "" {
+		// the last n bytes
+		n, err := strconv.ParseInt(bounds[1], 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > length {
+			n = length
+		}
+		return length - n, length - 1, true
+	}
+
+	start, err := strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil || start < 0 || start >= length {
+		return 0, 0, false
+	}
+	end := length - 1
+	if bounds[1] != "" {
+		if end, err = strconv.ParseInt(bounds[1], 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= length {
+			end = length - 1
+		}
+	}
+	return start, end, true
+}
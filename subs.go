@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	youtube "github.com/knadh/go-get-youtube/youtube"
+)
+
+// print the caption tracks of a video
+func printCaptions(video youtube.Video) {
+	if len(video.Captions) == 0 {
+		fmt.Println("No captions available")
+		return
+	}
+	for _, c := range video.Captions {
+		kind := "manual"
+		if c.Kind == "asr" {
+			kind = "auto-generated"
+		}
+		fmt.Printf("%-10s %-15s %s\n", c.LanguageCode, kind, c.Name)
+	}
+}
+
+// ytdownload subs URL
+func runSubs(args []string) int {
+	fs := newFlagSet("subs", "URL")
+	lang := fs.String("lang", "en", "Caption language code")
+	format := fs.String("sub-format", "vtt", "Caption format: "+strings.Join(youtube.CaptionFormats, ", "))
+	list := fs.Bool("list", false, "List the available caption tracks and exit")
+	asJSON := fs.Bool("json", false, "List caption tracks as JSON")
+	output := fs.String("o", "", "Output filename template, the extension is LANG.SUB_FORMAT (default \""+youtube.DEFAULT_TEMPLATE+"\")")
+	parseFlags(fs, args)
+
+	if *asJSON {
+		console = os.Stderr
+	}
+
+	video, err := fetchVideo(oneArg(fs), true)
+	if err != nil {
+		return exitFailed
+	}
+
+	switch {
+	case *list && *asJSON:
+		return dumpJSON(video.Captions)
+	case *list:
+		printCaptions(video)
+		return exitOK
+	}
+
+	filename, err := renderOutput(&video, *output, *lang+"."+*format)
+	if err != nil {
+		printErr(err)
+		return exitUsage
+	}
+	if err := video.DownloadCaption(*lang, *format, filename); err != nil {
+		printErr(err)
+		return exitFailed
+	}
+	fmt.Fprintln(console, "Downloaded captions:", filename)
+	return exitOK
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	youtube "github.com/knadh/go-get-youtube/youtube"
+)
+
+// check a -thumbnail-format flag
+func checkThumbnailFormat(format string) error {
+	if format != "jpg" && format != "png" {
+		return errors.New("Unknown thumbnail format: " + format)
+	}
+	return nil
+}
+
+func downloadThumbnail(video youtube.Video, size, format, output string) error {
+	if err := checkThumbnailFormat(format); err != nil {
+		printErr(err)
+		return err
+	}
+
+	filename := strings.TrimSuffix(output, filepath.Ext(output)) + "." + format
+	err := video.DownloadThumbnail(size, filename)
+	if err != nil {
+		printErr(err)
+		printErr("Unable to download thumbnail from Youtube.")
+	} else {
+		fmt.Fprintln(console, "Downloaded thumbnail:", filename)
+	}
+	return err
+}
+
+// ytdownload thumb URL
+func runThumb(args []string) int {
+	fs := newFlagSet("thumb", "URL")
+	size := fs.String("size", youtube.THUMB_BEST, "Thumbnail size: best, maxres, sd, hq, mq, default")
+	format := fs.String("thumbnail-format", "jpg", "Thumbnail image format: jpg, png")
+	output := fs.String("o", "", "Output filename template, the extension is THUMBNAIL_FORMAT (default \""+youtube.DEFAULT_TEMPLATE+"\")")
+	parseFlags(fs, args)
+	if err := checkThumbnailFormat(*format); err != nil {
+		printErr(err)
+		return exitUsage
+	}
+
+	video, err := fetchVideo(oneArg(fs), false)
+	if err != nil {
+		return exitFailed
+	}
+
+	filename, err := renderOutput(&video, *output, *format)
+	if err != nil {
+		printErr(err)
+		return exitUsage
+	}
+	if err := downloadThumbnail(video, *size, *format, filename); err != nil {
+		return exitFailed
+	}
+	return exitOK
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	youtube "github.com/knadh/go-get-youtube/youtube"
+)
+
+// the outcome of verifying a download
+type verification struct {
+	Info     string `json:"info"`
+	Filename string `json:"filename,omitempty"`
+	Status   string `json:"status"` // ok, skipped or failed
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ytdownload verify [DIR...]
+func runVerify(args []string) int {
+	fs := newFlagSet("verify", "[DIR...]")
+	asJSON := fs.Bool("json", false, "Print the results as JSON on stdout")
+	parseFlags(fs, args)
+
+	// keep stdout for JSON
+	if *asJSON {
+		jsonMode = true
+		console = os.Stderr
+	}
+
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	var ok, skipped, failed int
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !strings.HasSuffix(path, ".info.json") {
+				return nil
+			}
+
+			v := verifyInfo(path)
+			printJSON(v)
+			switch v.Status {
+			case "ok":
+				ok++
+				printVerbose("OK: %s", v.Filename)
+			case "skipped":
+				skipped++
+				printVerbose("Skipped %s: %s", path, v.Reason)
+			default:
+				failed++
+				name := v.Filename
+				if name == "" {
+					name = path
+				}
+				fmt.Fprintf(console, "FAILED: %s: %s\n", name, v.Reason)
+			}
+			return nil
+		})
+		if err != nil {
+			printErr(err)
+			return exitFailed
+		}
+	}
+
+	fmt.Fprintf(console, "\nVerify done: %d ok, %d skipped, %d failed\n", ok, skipped, failed)
+	if failed > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// verify the download recorded in an info file
+func verifyInfo(path string) verification {
+	v := verification{Info: path}
+	video, err := youtube.LoadInfoJSON(path)
+	if err != nil {
+		v.Status, v.Reason = "failed", err.Error()
+		return v
+	}
+	if video.DownloadInfo == nil {
+		v.Status, v.Reason = "skipped", "no download recorded"
+		return v
+	}
+
+	// the download may have been moved along with its info file
+	d := video.DownloadInfo
+	if _, err := os.Stat(d.Filename); os.IsNotExist(err) {
+		moved := filepath.Join(filepath.Dir(path), filepath.Base(d.Filename))
+		if _, err := os.Stat(moved); err == nil {
+			d.Filename = moved
+		}
+	}
+	v.Filename = d.Filename
+
+	if err := video.VerifyDownload(); err != nil {
+		v.Status, v.Reason = "failed", err.Error()
+		return v
+	}
+	v.Status = "ok"
+	return v
+}
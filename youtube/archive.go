@@ -0,0 +1,74 @@
+package youtube
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Archive records downloaded videos so that they can be skipped
+// when a playlist or channel is downloaded again
+type Archive interface {
+	// Has reports whether the video has already been downloaded
+	Has(id string) (bool, error)
+
+	// Add records a downloaded video and the Itag of its format
+	Add(id string, itag int) error
+}
+
+// FileArchive is an Archive kept in a text file with a "youtube <id> <itag>"
+// line per download. Files written by youtube-dl and yt-dlp, which leave out
+// the Itag, can be shared. The file is locked while it is read or written,
+// so several processes can use the same archive.
+type FileArchive struct {
+	Path string
+}
+
+// Returns an archive kept in the file at path, which is created on the
+// first download
+func NewFileArchive(path string) *FileArchive {
+	return &FileArchive{Path: path}
+}
+
+func (a *FileArchive) Has(id string) (bool, error) {
+	f, err := os.Open(a.Path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("Unable to open archive %q: %s", a.Path, err)
+	}
+	defer f.Close()
+
+	if err := lockFile(f, false); err != nil {
+		return false, fmt.Errorf("Unable to lock archive %q: %s", a.Path, err)
+	}
+	defer unlockFile(f)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "youtube" && fields[1] == id {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+func (a *FileArchive) Add(id string, itag int) error {
+	f, err := os.OpenFile(a.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to open archive %q: %s", a.Path, err)
+	}
+	defer f.Close()
+
+	if err := lockFile(f, true); err != nil {
+		return fmt.Errorf("Unable to lock archive %q: %s", a.Path, err)
+	}
+	defer unlockFile(f)
+
+	if _, err := fmt.Fprintf(f, "youtube %s %d\n", id, itag); err != nil {
+		return fmt.Errorf("Unable to write to archive %q: %s", a.Path, err)
+	}
+	return nil
+}
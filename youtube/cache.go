@@ -0,0 +1,155 @@
+package youtube
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// how long cached metadata is kept by default, see DiskCache
+const METADATA_TTL = 24 * time.Hour
+
+// how long format URLs of cached metadata are used, if they don't have an
+// expire parameter
+const METADATA_URL_TTL = 5 * time.Hour
+
+// A cache of raw metadata responses (the player response), keyed by video
+// ID and the type of client that fetched them, eg: WEB. Set it as a
+// Client's Cache. Methods may be called concurrently.
+type MetadataCache interface {
+	// Returns a cached response and the time it was stored, or false if
+	// there is none
+	Get(videoID, clientType string) ([]byte, time.Time, bool)
+
+	// Stores a response
+	Put(videoID, clientType string, data []byte) error
+}
+
+// A MetadataCache of files in a directory, eg: ~/.cache/ytdownload/metadata
+type DiskCache struct {
+	Dir string
+
+	// how long responses are kept, for their static fields such as the
+	// title. Their format URLs are only used until they expire.
+	TTL time.Duration
+}
+
+// Returns a disk cache in dir that keeps responses for METADATA_TTL
+func NewDiskCache(dir string) *DiskCache {
+	return &DiskCache{Dir: dir, TTL: METADATA_TTL}
+}
+
+// the file of a response
+func (d *DiskCache) path(videoID, clientType string) string {
+	return filepath.Join(d.Dir, url.PathEscape(clientType), url.PathEscape(videoID)+".txt")
+}
+
+// Returns a cached response, unless it's older than the cache's TTL
+func (d *DiskCache) Get(videoID, clientType string) ([]byte, time.Time, bool) {
+	path := d.path(videoID, clientType)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	stored := info.ModTime()
+	if d.TTL > 0 && time.Since(stored) > d.TTL {
+		os.Remove(path)
+		return nil, time.Time{}, false
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return data, stored, true
+}
+
+// Stores a response in a file
+func (d *DiskCache) Put(videoID, clientType string, data []byte) error {
+	path := d.path(videoID, clientType)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("Unable to create directory %q: %s", filepath.Dir(path), err)
+	}
+
+	// write atomically, so that concurrent readers never see a partial
+	// response
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return fmt.Errorf("Unable to write to file %q: %s", path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Unable to write to file %q: %s", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Unable to write to file %q: %s", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Unable to write to file %q: %s", path, err)
+	}
+	return nil
+}
+
+// how metadata is fetched
+const (
+	cacheFresh = iota // from the cache if its format URLs are valid
+	cacheStale        // from the cache even if its format URLs expired
+	cacheSkip         // from Youtube
+)
+
+// Returns a video's metadata from the cache, or nil
+func (c *Client) cached(video_id string, mode int) *Video {
+	if c.Cache == nil || mode == cacheSkip {
+		return nil
+	}
+	data, stored, ok := c.Cache.Get(video_id, INNERTUBE_CLIENT_NAME)
+	if !ok {
+		return nil
+	}
+	meta, err := parseMeta(video_id, string(data))
+	if err != nil {
+		return nil
+	}
+	if mode == cacheFresh && !urlsValid(meta, stored) {
+		return nil
+	}
+	c.logger().Debug("Using cached metadata", "id", video_id, "stored", stored)
+	return meta
+}
+
+// store a metadata response, if the video can be played and isn't live
+func (c *Client) cache(video_id, query_string string, meta *Video) {
+	if c.Cache == nil || meta.IsLive || meta.IsUpcoming {
+		return
+	}
+	if meta.PlayabilityStatus != "" && meta.PlayabilityStatus != "OK" {
+		return
+	}
+	if err := c.Cache.Put(video_id, INNERTUBE_CLIENT_NAME, []byte(query_string)); err != nil {
+		c.logger().Warn("Failed to cache metadata", "error", err)
+	}
+}
+
+// check if the format URLs of cached metadata are valid for at least
+// another minute
+func urlsValid(meta *Video, stored time.Time) bool {
+	deadline := time.Now().Add(time.Minute)
+	for _, formats := range [][]Format{meta.Formats, meta.AdaptiveFormats} {
+		for i := range formats {
+			expires := formats[i].Expires()
+			if expires.IsZero() {
+				expires = stored.Add(METADATA_URL_TTL)
+			}
+			if expires.Before(deadline) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package youtube
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Caption formats accepted by DownloadCaption
+var CaptionFormats = []string{"vtt", "srv3", "ttml", "json3"}
+
+// Returns the caption track for a language code. Manual captions are
+// preferred over auto-generated ones. If there is no track in the language,
+// a translatable track is returned with the translation language set in
+// its url. Returns nil if there is no such track.
+func (v *Video) CaptionByLanguage(lang string) *Caption {
+	var asr, translatable *Caption
+	for i := range v.Captions {
+		c := &v.Captions[i]
+		switch {
+		case c.LanguageCode == lang && c.Kind != "asr":
+			return c
+		case c.LanguageCode == lang && asr == nil:
+			asr = c
+		case c.IsTranslatable && (translatable == nil || translatable.Kind == "asr"):
+			translatable = c
+		}
+	}
+
+	if asr != nil {
+		return asr
+	}
+	if translatable == nil {
+		return nil
+	}
+
+	// ask Youtube for a translation
+	c := *translatable
+	u, err := url.Parse(c.Url)
+	if err != nil {
+		return nil
+	}
+	q := u.Query()
+	q.Set("tlang", lang)
+	u.RawQuery = q.Encode()
+	c.Url = u.String()
+	c.LanguageCode = lang
+	return &c
+}
+
+// Downloads the captions in a language to filename, in the given format
+// (vtt, srv3, ttml or json3)
+func (v *Video) DownloadCaption(lang, format, filename string) error {
+	c := v.CaptionByLanguage(lang)
+	if c == nil {
+		return fmt.Errorf("No captions available in %q", lang)
+	}
+
+	valid := false
+	for _, f := range CaptionFormats {
+		valid = valid || f == format
+	}
+	if !valid {
+		return fmt.Errorf("Unknown caption format: %s", format)
+	}
+
+	u, err := url.Parse(c.Url)
+	if err != nil {
+		return fmt.Errorf("Invalid caption url: %s", err)
+	}
+	q := u.Query()
+	q.Set("fmt", format)
+	u.RawQuery = q.Encode()
+
+	resp, err := v.client.get(u.String())
+	if err != nil {
+		return fmt.Errorf("Request failed: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Caption request failed: %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	// create the output directory
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("Unable to create directory %q: %s", dir, err)
+		}
+	}
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("Unable to write to file %q: %s", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package youtube
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Youtube channel pages are relative to this url
+	URL_CHANNEL = "https://www.youtube.com"
+
+	// Channel tabs accepted by Channel.Videos
+	CHANNEL_VIDEOS = "videos"
+	CHANNEL_SHORTS = "shorts"
+	CHANNEL_LIVE   = "streams"
+)
+
+var (
+	// channel ids are "UC" followed by 22 base64 characters
+	channelId = regexp.MustCompile(`^UC[\w-]{22}$`)
+
+	// relative publish times: "3 days ago", "Streamed 1 year ago"
+	relativeTime = regexp.MustCompile(`(\d+)\s+(second|minute|hour|day|week|month|year)s?\s+ago`)
+)
+
+// holds a channel's information
+type Channel struct {
+	Id          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Url         string `json:"url"`
+
+	// the client the channel was fetched with
+	client *Client
+}
+
+// a video listed on a channel tab
+type ChannelVideo struct {
+	Id            string `json:"id"`
+	Title         string `json:"title"`
+	LengthSeconds int    `json:"length_seconds"`
+
+	// Youtube only lists relative publish times ("3 days ago"). Published
+	// is the latest time the video could have been published at; the exact
+	// date is in the video's metadata.
+	Published     time.Time `json:"published"`
+	PublishedText string    `json:"published_text"`
+}
+
+// a channel page's initial data
+type channelPage struct {
+	Contents struct {
+		TwoColumnBrowseResultsRenderer struct {
+			Tabs []struct {
+				TabRenderer struct {
+					Title    string `json:"title"`
+					Selected bool   `json:"selected"`
+					Content  struct {
+						RichGridRenderer struct {
+							Contents json.RawMessage `json:"contents"`
+						} `json:"richGridRenderer"`
+					} `json:"content"`
+				} `json:"tabRenderer"`
+			} `json:"tabs"`
+		} `json:"twoColumnBrowseResultsRenderer"`
+	} `json:"contents"`
+	Metadata struct {
+		ChannelMetadataRenderer struct {
+			Title       string `json:"title"`
+			Description string `json:"description"`
+			ExternalID  string `json:"externalId"`
+			ChannelURL  string `json:"channelUrl"`
+		} `json:"channelMetadataRenderer"`
+	} `json:"metadata"`
+	Alerts []struct {
+		AlertRenderer struct {
+			Type string `json:"type"`
+			Text text   `json:"text"`
+		} `json:"alertRenderer"`
+	} `json:"alerts"`
+}
+
+// an item in a channel tab's grid
+type channelItem struct {
+	RichItemRenderer struct {
+		Content struct {
+			VideoRenderer *struct {
+				VideoID           string `json:"videoId"`
+				Title             text   `json:"title"`
+				PublishedTimeText text   `json:"publishedTimeText"`
+				LengthText        text   `json:"lengthText"`
+			} `json:"videoRenderer"`
+			ReelItemRenderer *struct {
+				VideoID  string `json:"videoId"`
+				Headline text   `json:"headline"`
+			} `json:"reelItemRenderer"`
+			ShortsLockupViewModel *struct {
+				OnTap struct {
+					InnertubeCommand struct {
+						ReelWatchEndpoint struct {
+							VideoID string `json:"videoId"`
+						} `json:"reelWatchEndpoint"`
+					} `json:"innertubeCommand"`
+				} `json:"onTap"`
+				OverlayMetadata struct {
+					PrimaryText struct {
+						Content string `json:"content"`
+					} `json:"primaryText"`
+				} `json:"overlayMetadata"`
+			} `json:"shortsLockupViewModel"`
+		} `json:"content"`
+	} `json:"richItemRenderer"`
+	ContinuationItemRenderer *continuationItem `json:"continuationItemRenderer"`
+}
+
+// resolve a channel id, @handle, /c/ or /user/ name, or channel URL
+// to the path of the channel's page
+func channelPath(input string) string {
+	input = strings.TrimSpace(input)
+
+	if u, err := url.Parse(input); err == nil && strings.HasSuffix(u.Host, "youtube.com") {
+		input = u.Path
+	}
+	input = strings.Trim(input, "/")
+
+	switch {
+	case channelId.MatchString(input):
+		return "/channel/" + input
+	case strings.HasPrefix(input, "@"):
+		return "/" + strings.SplitN(input, "/", 2)[0]
+	case strings.HasPrefix(input, "channel/"), strings.HasPrefix(input, "c/"), strings.HasPrefix(input, "user/"):
+		parts := strings.SplitN(input, "/", 3)
+		return "/" + parts[0] + "/" + parts[1]
+	}
+	return "/@" + input
+}
+
+// given a channel id, @handle, /c/ or /user/ name or URL, get the
+// channel's information from youtube
+func GetChannel(channel string) (Channel, error) {
+	return DefaultClient.GetChannel(channel)
+}
+
+// given a channel id, @handle, /c/ or /user/ name or URL, get the
+// channel's information from youtube
+func (c *Client) GetChannel(channel string) (Channel, error) {
+	page, err := c.fetchChannelPage(URL_CHANNEL + channelPath(channel))
+	if err != nil {
+		return Channel{}, err
+	}
+
+	meta := page.Metadata.ChannelMetadataRenderer
+	ch := Channel{
+		Id:          meta.ExternalID,
+		Title:       meta.Title,
+		Description: meta.Description,
+		Url:         meta.ChannelURL,
+		client:      c,
+	}
+	if ch.Url == "" {
+		ch.Url = URL_CHANNEL + "/channel/" + ch.Id
+	}
+	return ch, nil
+}
+
+// fetch and parse a channel page
+func (c *Client) fetchChannelPage(url string) (*channelPage, error) {
+	html, err := c.fetchPage(url)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := extractInitialData(html)
+	if err != nil {
+		return nil, err
+	}
+	return parseChannelPage(data)
+}
+
+// parse a channel page's initial data
+func parseChannelPage(data []byte) (*channelPage, error) {
+	var page channelPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, fmt.Errorf("Invalid channel page: %s", err)
+	}
+
+	if page.Metadata.ChannelMetadataRenderer.ExternalID == "" {
+		for _, a := range page.Alerts {
+			if a.AlertRenderer.Type == "ERROR" {
+				return nil, errors.New(a.AlertRenderer.Text.String())
+			}
+		}
+		return nil, errors.New("No channel found in page")
+	}
+	return &page, nil
+}
+
+// Returns all videos listed on a channel tab (videos, shorts or streams),
+// newest first
+func (c *Channel) Videos(tab string) ([]ChannelVideo, error) {
+	var videos []ChannelVideo
+	err := c.VideosFunc(tab, func(v ChannelVideo) bool {
+		videos = append(videos, v)
+		return true
+	})
+	return videos, err
+}
+
+// Calls fn for every video listed on a channel tab, newest first, fetching
+// further pages as needed. Stops early when fn returns false.
+func (c *Channel) VideosFunc(tab string, fn func(ChannelVideo) bool) error {
+	page, err := c.client.fetchChannelPage(URL_CHANNEL + "/channel/" + c.Id + "/" + tab)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	videos, token, err := parseChannelTab(page, now)
+	if err != nil {
+		return err
+	}
+
+	for {
+		for _, v := range videos {
+			if !fn(v) {
+				return nil
+			}
+		}
+		if token == "" {
+			return nil
+		}
+
+		data, err := c.client.browse(token)
+		if err != nil {
+			return err
+		}
+		if videos, token, err = parseChannelContinuation(data, now); err != nil {
+			return err
+		}
+	}
+}
+
+// parse the selected tab of a channel page. Returns the first page of
+// videos and the continuation token for the next page, if any.
+func parseChannelTab(page *channelPage, now time.Time) ([]ChannelVideo, string, error) {
+	for _, tab := range page.Contents.TwoColumnBrowseResultsRenderer.Tabs {
+		if tab.TabRenderer.Selected {
+			return parseChannelItems(tab.TabRenderer.Content.RichGridRenderer.Contents, now)
+		}
+	}
+	return nil, "", errors.New("Channel tab not found")
+}
+
+// parse a browse continuation response for a channel tab
+func parseChannelContinuation(data []byte, now time.Time) ([]ChannelVideo, string, error) {
+	items, err := parseContinuation(data)
+	if err != nil {
+		return nil, "", err
+	}
+	return parseChannelItems(items, now)
+}
+
+// parse the items of a channel tab's grid
+func parseChannelItems(data json.RawMessage, now time.Time) ([]ChannelVideo, string, error) {
+	if data == nil {
+		return nil, "", nil
+	}
+
+	var items []channelItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, "", fmt.Errorf("Invalid channel items: %s", err)
+	}
+
+	var (
+		videos []ChannelVideo
+		token  string
+	)
+	for _, item := range items {
+		if c := item.ContinuationItemRenderer; c != nil {
+			token = c.ContinuationEndpoint.ContinuationCommand.Token
+			continue
+		}
+
+		content := item.RichItemRenderer.Content
+		switch {
+		case content.VideoRenderer != nil:
+			v := content.VideoRenderer
+			published := v.PublishedTimeText.String()
+			videos = append(videos, ChannelVideo{
+				Id:            v.VideoID,
+				Title:         v.Title.String(),
+				LengthSeconds: parseTimestamp(v.LengthText.String()),
+				Published:     parseRelativeTime(published, now),
+				PublishedText: published,
+			})
+		case content.ReelItemRenderer != nil:
+			v := content.ReelItemRenderer
+			videos = append(videos, ChannelVideo{
+				Id:    v.VideoID,
+				Title: v.Headline.String(),
+			})
+		case content.ShortsLockupViewModel != nil:
+			v := content.ShortsLockupViewModel
+			videos = append(videos, ChannelVideo{
+				Id:    v.OnTap.InnertubeCommand.ReelWatchEndpoint.VideoID,
+				Title: v.OverlayMetadata.PrimaryText.Content,
+			})
+		}
+	}
+
+	return videos, token, nil
+}
+
+// convert a relative time ("3 days ago") to the latest time it can refer to
+func parseRelativeTime(s string, now time.Time) time.Time {
+	m := relativeTime.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}
+	}
+
+	n, _ := strconv.Atoi(m[1])
+	switch m[2] {
+	case "second":
+		return now.Add(-time.Duration(n) * time.Second)
+	case "minute":
+		return now.Add(-time.Duration(n) * time.Minute)
+	case "hour":
+		return now.Add(-time.Duration(n) * time.Hour)
+	case "day":
+		return now.AddDate(0, 0, -n)
+	case "week":
+		return now.AddDate(0, 0, -7*n)
+	case "month":
+		return now.AddDate(0, -n, 0)
+	}
+	return now.AddDate(-n, 0, 0)
+}
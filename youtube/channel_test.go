@@ -0,0 +1,125 @@
+package youtube
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseChannelTab(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		file   string
+		videos []ChannelVideo
+		token  string
+	}{
+		{
+			file: "channel_videos.json",
+			videos: []ChannelVideo{
+				{
+					Id:            "dQw4w9WgXcQ",
+					Title:         "Never Gonna Give You Up",
+					LengthSeconds: 213,
+					Published:     time.Date(2024, 6, 12, 12, 0, 0, 0, time.UTC),
+					PublishedText: "3 days ago",
+				},
+				{
+					Id:            "yPYZpwSpKmA",
+					Title:         "Together Forever",
+					LengthSeconds: 3805,
+					Published:     time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+					PublishedText: "Streamed 2 weeks ago",
+				},
+			},
+			token: "4qmFsgKrCBIYVUN1QVhGa2dzdzFMN3hhQ2ZuZDVKSk93",
+		},
+		{
+			file: "channel_shorts.json",
+			videos: []ChannelVideo{
+				{Id: "cccccccccc1", Title: "Rickrolled in 10 seconds"},
+				{Id: "cccccccccc2", Title: "Behind the scenes"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			page, err := parseChannelPage(readTestdata(t, test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			meta := page.Metadata.ChannelMetadataRenderer
+			if meta.ExternalID != "UCuAXFkgsw1L7xaCfnd5JJOw" || meta.Title != "Rick Astley" {
+				t.Errorf("got channel %+v", meta)
+			}
+
+			videos, token, err := parseChannelTab(page, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(videos, test.videos) {
+				t.Errorf("got %+v\nwant %+v", videos, test.videos)
+			}
+			if token != test.token {
+				t.Errorf("got token %q, want %q", token, test.token)
+			}
+		})
+	}
+}
+
+func TestParseChannelContinuation(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	videos, token, err := parseChannelContinuation(readTestdata(t, "channel_continuation.json"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ChannelVideo{{
+		Id:            "BeyEGebJ1l4",
+		Title:         "Whenever You Need Somebody",
+		LengthSeconds: 236,
+		Published:     time.Date(2023, 6, 15, 12, 0, 0, 0, time.UTC),
+		PublishedText: "1 year ago",
+	}}
+	if !reflect.DeepEqual(videos, want) {
+		t.Errorf("got %+v\nwant %+v", videos, want)
+	}
+	if token != "" {
+		t.Errorf("got token %q on the last page", token)
+	}
+}
+
+func TestParseChannelErrors(t *testing.T) {
+	if _, err := parseChannelPage(readTestdata(t, "channel_missing.json")); err == nil || err.Error() != "This channel does not exist." {
+		t.Errorf("got %v for a missing channel", err)
+	}
+	if _, err := parseChannelPage([]byte(`{}`)); err == nil || err.Error() != "No channel found in page" {
+		t.Errorf("got %v for an empty page", err)
+	}
+
+	// a page without a selected tab
+	page, err := parseChannelPage([]byte(`{"metadata": {"channelMetadataRenderer": {"externalId": "UCuAXFkgsw1L7xaCfnd5JJOw"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := parseChannelTab(page, time.Now()); err == nil {
+		t.Error("parsed a page without a selected tab")
+	}
+}
+
+func TestChannelPath(t *testing.T) {
+	tests := map[string]string{
+		"UCuAXFkgsw1L7xaCfnd5JJOw": "/channel/UCuAXFkgsw1L7xaCfnd5JJOw",
+		"@RickAstleyYT":            "/@RickAstleyYT",
+		"RickAstleyYT":             "/@RickAstleyYT",
+		"https://www.youtube.com/@RickAstleyYT/videos":             "/@RickAstleyYT",
+		"https://www.youtube.com/channel/UCuAXFkgsw1L7xaCfnd5JJOw": "/channel/UCuAXFkgsw1L7xaCfnd5JJOw",
+		"https://www.youtube.com/user/RickAstleyVEVO/videos":       "/user/RickAstleyVEVO",
+		"c/RickAstley": "/c/RickAstley",
+	}
+	for input, want := range tests {
+		if got := channelPath(input); got != want {
+			t.Errorf("channelPath(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package youtube
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Origin of the InnerTube requests signed with SAPISIDHASH
+const URL_ORIGIN = "https://www.youtube.com"
+
+// A CookieJar is an http.CookieJar that can be loaded from and saved to a
+// Netscape cookies.txt file, as exported by browsers and used by curl
+type CookieJar struct {
+	mu      sync.Mutex
+	cookies []*jarCookie
+}
+
+// a cookie with its cookies.txt attributes
+type jarCookie struct {
+	domain     string // without a leading dot
+	subdomains bool   // false for host-only cookies
+	path       string
+	secure     bool
+	httpOnly   bool
+	expires    time.Time // zero for session cookies
+	name       string
+	value      string
+}
+
+// Returns an empty cookie jar
+func NewCookieJar() *CookieJar {
+	return &CookieJar{}
+}
+
+// Loads a cookie jar from a Netscape cookies.txt file. Expired cookies are
+// dropped.
+func LoadCookies(filename string) (*CookieJar, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	jar := NewCookieJar()
+	now := time.Now()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		if httpOnly {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("Invalid cookie on line %d of %q", n, filename)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cookie expiry on line %d of %q", n, filename)
+		}
+
+		c := &jarCookie{
+			domain:     strings.TrimPrefix(strings.ToLower(fields[0]), "."),
+			subdomains: strings.EqualFold(fields[1], "TRUE"),
+			path:       fields[2],
+			secure:     strings.EqualFold(fields[3], "TRUE"),
+			httpOnly:   httpOnly,
+			name:       fields[5],
+			value:      fields[6],
+		}
+		if expires > 0 {
+			if c.expires = time.Unix(expires, 0); c.expires.Before(now) {
+				continue
+			}
+		}
+		jar.set(c)
+	}
+	return jar, scanner.Err()
+}
+
+// Saves the cookies to a Netscape cookies.txt file. Session cookies are
+// saved with an expiry of 0.
+func (j *CookieJar) Save(filename string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n\n")
+	now := time.Now()
+	for _, c := range j.cookies {
+		if c.expired(now) {
+			continue
+		}
+
+		domain := c.domain
+		if c.subdomains {
+			domain = "." + domain
+		}
+		if c.httpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		var expires int64
+		if !c.expires.IsZero() {
+			expires = c.expires.Unix()
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, boolField(c.subdomains), c.path, boolField(c.secure), expires, c.name, c.value)
+	}
+
+	// write to a temporary file first, so an error doesn't lose the cookies
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".cookies")
+	if err != nil {
+		return fmt.Errorf("Unable to write to file %q: %s", filename, err)
+	}
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Unable to write to file %q: %s", filename, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Unable to write to file %q: %s", filename, err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Unable to write to file %q: %s", filename, err)
+	}
+	return nil
+}
+
+// Stores the cookies of a response from u
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	for _, hc := range cookies {
+		c := &jarCookie{
+			domain:   host,
+			path:     hc.Path,
+			secure:   hc.Secure,
+			httpOnly: hc.HttpOnly,
+			name:     hc.Name,
+			value:    hc.Value,
+		}
+
+		if hc.Domain != "" {
+			domain := strings.TrimPrefix(strings.ToLower(hc.Domain), ".")
+			// a site can only set cookies for itself and its parent domains
+			if host != domain && !strings.HasSuffix(host, "."+domain) {
+				continue
+			}
+			c.domain, c.subdomains = domain, true
+		}
+
+		if c.path == "" || c.path[0] != '/' {
+			// the directory of the request path
+			c.path = "/"
+			if i := strings.LastIndex(u.Path, "/"); i > 0 {
+				c.path = u.Path[:i]
+			}
+		}
+
+		switch {
+		case hc.MaxAge < 0:
+			c.expires = now.Add(-time.Second)
+		case hc.MaxAge > 0:
+			c.expires = now.Add(time.Duration(hc.MaxAge) * time.Second)
+		case !hc.Expires.IsZero():
+			c.expires = hc.Expires
+		}
+		j.set(c)
+	}
+}
+
+// Returns the cookies to send in a request to u
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+
+	var cookies []*http.Cookie
+	for _, c := range j.cookies {
+		if c.expired(now) || (c.secure && u.Scheme != "https") {
+			continue
+		}
+		if host != c.domain && !(c.subdomains && strings.HasSuffix(host, "."+c.domain)) {
+			continue
+		}
+		if !pathMatch(path, c.path) {
+			continue
+		}
+		cookies = append(cookies, &http.Cookie{Name: c.name, Value: c.value})
+	}
+	return cookies
+}
+
+// add or replace a cookie, or remove it if it's expired
+func (j *CookieJar) set(c *jarCookie) {
+	for i, old := range j.cookies {
+		if old.domain == c.domain && old.path == c.path && old.name == c.name {
+			j.cookies = append(j.cookies[:i], j.cookies[i+1:]...)
+			break
+		}
+	}
+	if !c.expired(time.Now()) {
+		j.cookies = append(j.cookies, c)
+	}
+}
+
+func (c *jarCookie) expired(now time.Time) bool {
+	return !c.expires.IsZero() && !c.expires.After(now)
+}
+
+// check if a request path is within a cookie path
+func pathMatch(path, cookiePath string) bool {
+	if !strings.HasPrefix(path, cookiePath) {
+		return false
+	}
+	return len(path) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+}
+
+func boolField(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// the SAPISIDHASH authorization header for a logged-in session, or "" if
+// the client's cookies have no SAPISID
+func (c *Client) sapisidHash(now time.Time) string {
+	jar := c.httpClient().Jar
+	if jar == nil {
+		return ""
+	}
+
+	u, _ := url.Parse(URL_ORIGIN)
+	var sapisid string
+	for _, cookie := range jar.Cookies(u) {
+		switch cookie.Name {
+		case "SAPISID":
+			sapisid = cookie.Value
+		case "__Secure-3PAPISID":
+			if sapisid == "" {
+				sapisid = cookie.Value
+			}
+		}
+	}
+	if sapisid == "" {
+		return ""
+	}
+
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sum := sha1.Sum([]byte(ts + " " + sapisid + " " + URL_ORIGIN))
+	return fmt.Sprintf("SAPISIDHASH %s_%x", ts, sum)
+}
+
+// sign an InnerTube request for a logged-in session
+func (c *Client) authorize(req *http.Request) {
+	if auth := c.sapisidHash(time.Now()); auth != "" {
+		req.Header.Set("Authorization", auth)
+		req.Header.Set("Origin", URL_ORIGIN)
+		req.Header.Set("X-Origin", URL_ORIGIN)
+		req.Header.Set("X-Goog-AuthUser", "0")
+	}
+}
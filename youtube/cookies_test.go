@@ -0,0 +1,162 @@
+package youtube
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the names and values of the cookies a jar sends to a URL
+func jarCookies(t *testing.T, jar http.CookieJar, rawurl string) []string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, c := range jar.Cookies(u) {
+		out = append(out, c.Name+"="+c.Value)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestCookiesRoundTrip(t *testing.T) {
+	future := time.Now().Add(24 * time.Hour).Unix()
+	file := strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		"",
+		".youtube.com\tTRUE\t/\tTRUE\t" + strconv.FormatInt(future, 10) + "\tSAPISID\tsapisid",
+		"#HttpOnly_.youtube.com\tTRUE\t/\tTRUE\t" + strconv.FormatInt(future, 10) + "\tSID\tsid",
+		"www.youtube.com\tFALSE\t/\tFALSE\t0\tPREF\tf6=8",
+		".youtube.com\tTRUE\t/feed\tFALSE\t0\tFEED\tfeed",
+		".youtube.com\tTRUE\t/\tFALSE\t1\tOLD\texpired",
+		"",
+	}, "\n")
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "cookies.txt")
+	if err := os.WriteFile(filename, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	jar, err := LoadCookies(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"https://www.youtube.com/watch":  {"PREF=f6=8", "SAPISID=sapisid", "SID=sid"},
+		"https://m.youtube.com/":         {"SAPISID=sapisid", "SID=sid"},
+		"http://www.youtube.com/":        {"PREF=f6=8"},
+		"https://www.youtube.com/feed/x": {"FEED=feed", "PREF=f6=8", "SAPISID=sapisid", "SID=sid"},
+		"https://www.youtube.com/feeds":  {"PREF=f6=8", "SAPISID=sapisid", "SID=sid"},
+		"https://example.com/":           nil,
+	}
+	for u, want := range tests {
+		if got := jarCookies(t, jar, u); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", u, got, want)
+		}
+	}
+
+	// cookies set by responses are saved with the loaded ones
+	u, _ := url.Parse("https://www.youtube.com/watch")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "PREF", Value: "f6=400", MaxAge: 3600},
+		{Name: "SID", Domain: ".youtube.com", Path: "/", MaxAge: -1},
+		{Name: "EVIL", Value: "x", Domain: "example.com"},
+	})
+
+	saved := filepath.Join(dir, "saved.txt")
+	if err := jar.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# Netscape HTTP Cookie File\n") || strings.Contains(string(data), "EVIL") || strings.Contains(string(data), "OLD") {
+		t.Errorf("saved:\n%s", data)
+	}
+	if !strings.Contains(string(data), "www.youtube.com\tFALSE\t/\tFALSE\t") {
+		t.Errorf("host-only cookie not saved:\n%s", data)
+	}
+
+	loaded, err := LoadCookies(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := jarCookies(t, loaded, "https://www.youtube.com/"), []string{"PREF=f6=400", "SAPISID=sapisid"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reloaded: got %v, want %v", got, want)
+	}
+
+	// saved and loaded again, the jar is unchanged
+	if err := loaded.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	again, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("round trip changed the file:\n%s\nwant:\n%s", again, data)
+	}
+}
+
+func TestLoadCookiesErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"fields": ".youtube.com\tTRUE\t/\tTRUE\t0\tSID\n",
+		"expiry": ".youtube.com\tTRUE\t/\tTRUE\tnever\tSID\tsid\n",
+	}
+	for name, file := range tests {
+		filename := filepath.Join(dir, name)
+		os.WriteFile(filename, []byte(file), 0644)
+		if _, err := LoadCookies(filename); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+	if _, err := LoadCookies(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("missing file: got %v", err)
+	}
+}
+
+func TestSapisidHash(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	u, _ := url.Parse(URL_ORIGIN)
+
+	c := &Client{HTTP: &http.Client{}}
+	if got := c.sapisidHash(now); got != "" {
+		t.Errorf("without a jar: got %q", got)
+	}
+
+	jar := NewCookieJar()
+	c.HTTP.Jar = jar
+	if got := c.sapisidHash(now); got != "" {
+		t.Errorf("without SAPISID: got %q", got)
+	}
+
+	// the secure cookie stands in for SAPISID
+	jar.SetCookies(u, []*http.Cookie{{Name: "__Secure-3PAPISID", Value: "SAPISIDvalue", Domain: ".youtube.com", Secure: true}})
+	want := "SAPISIDHASH 1700000000_0a4ca0ae5507d5981e6bb5ebe0f2ce2ab998ee51"
+	if got := c.sapisidHash(now); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "SAPISID", Value: "SAPISIDvalue", Domain: ".youtube.com", Secure: true}})
+	jar.SetCookies(u, []*http.Cookie{{Name: "__Secure-3PAPISID", Value: "other", Domain: ".youtube.com", Secure: true}})
+	if got := c.sapisidHash(now); got != want {
+		t.Errorf("with SAPISID: got %q, want %q", got, want)
+	}
+
+	req, _ := http.NewRequest("POST", URL_ORIGIN+"/youtubei/v1/player", nil)
+	c.authorize(req)
+	if req.Header.Get("Authorization") == "" || req.Header.Get("Origin") != URL_ORIGIN || req.Header.Get("X-Goog-AuthUser") != "0" {
+		t.Errorf("authorize set %v", req.Header)
+	}
+}
@@ -0,0 +1,495 @@
+package youtube
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a DASH media presentation description (MPD)
+type mpd struct {
+	XMLName                   xml.Name    `xml:"MPD"`
+	Xmlns                     string      `xml:"xmlns,attr,omitempty"`
+	Type                      string      `xml:"type,attr,omitempty"`
+	Profiles                  string      `xml:"profiles,attr,omitempty"`
+	MinBufferTime             string      `xml:"minBufferTime,attr,omitempty"`
+	MediaPresentationDuration string      `xml:"mediaPresentationDuration,attr,omitempty"`
+	MinimumUpdatePeriod       string      `xml:"minimumUpdatePeriod,attr,omitempty"`
+	AvailabilityStartTime     string      `xml:"availabilityStartTime,attr,omitempty"`
+	TimeShiftBufferDepth      string      `xml:"timeShiftBufferDepth,attr,omitempty"`
+	BaseURL                   string      `xml:"BaseURL,omitempty"`
+	Periods                   []mpdPeriod `xml:"Period"`
+}
+
+type mpdPeriod struct {
+	Id             string             `xml:"id,attr,omitempty"`
+	Start          string             `xml:"start,attr,omitempty"`
+	Duration       string             `xml:"duration,attr,omitempty"`
+	BaseURL        string             `xml:"BaseURL,omitempty"`
+	AdaptationSets []mpdAdaptationSet `xml:"AdaptationSet"`
+}
+
+type mpdAdaptationSet struct {
+	Id               string              `xml:"id,attr,omitempty"`
+	ContentType      string              `xml:"contentType,attr,omitempty"`
+	MimeType         string              `xml:"mimeType,attr,omitempty"`
+	Codecs           string              `xml:"codecs,attr,omitempty"`
+	Lang             string              `xml:"lang,attr,omitempty"`
+	SegmentAlignment bool                `xml:"segmentAlignment,attr,omitempty"`
+	SubsegmentAlign  bool                `xml:"subsegmentAlignment,attr,omitempty"`
+	BaseURL          string              `xml:"BaseURL,omitempty"`
+	SegmentTemplate  *mpdSegmentTemplate `xml:"SegmentTemplate"`
+	SegmentList      *mpdSegmentList     `xml:"SegmentList"`
+	Representations  []mpdRepresentation `xml:"Representation"`
+}
+
+type mpdRepresentation struct {
+	Id                string              `xml:"id,attr"`
+	MimeType          string              `xml:"mimeType,attr,omitempty"`
+	Codecs            string              `xml:"codecs,attr,omitempty"`
+	Bandwidth         int                 `xml:"bandwidth,attr"`
+	Width             int                 `xml:"width,attr,omitempty"`
+	Height            int                 `xml:"height,attr,omitempty"`
+	FrameRate         string              `xml:"frameRate,attr,omitempty"`
+	AudioSamplingRate string              `xml:"audioSamplingRate,attr,omitempty"`
+	BaseURL           string              `xml:"BaseURL,omitempty"`
+	SegmentBase       *mpdSegmentBase     `xml:"SegmentBase"`
+	SegmentTemplate   *mpdSegmentTemplate `xml:"SegmentTemplate"`
+	SegmentList       *mpdSegmentList     `xml:"SegmentList"`
+}
+
+type mpdSegmentBase struct {
+	IndexRange     string  `xml:"indexRange,attr,omitempty"`
+	Initialization *mpdURL `xml:"Initialization"`
+}
+
+type mpdURL struct {
+	SourceURL string `xml:"sourceURL,attr,omitempty"`
+	Range     string `xml:"range,attr,omitempty"`
+}
+
+type mpdSegmentTemplate struct {
+	Timescale      int64        `xml:"timescale,attr,omitempty"`
+	Duration       int64        `xml:"duration,attr,omitempty"`
+	StartNumber    *int64       `xml:"startNumber,attr"`
+	Media          string       `xml:"media,attr,omitempty"`
+	Initialization string       `xml:"initialization,attr,omitempty"`
+	Timeline       *mpdTimeline `xml:"SegmentTimeline"`
+}
+
+type mpdSegmentList struct {
+	Timescale      int64           `xml:"timescale,attr,omitempty"`
+	Duration       int64           `xml:"duration,attr,omitempty"`
+	StartNumber    *int64          `xml:"startNumber,attr"`
+	Initialization *mpdURL         `xml:"Initialization"`
+	SegmentURLs    []mpdSegmentURL `xml:"SegmentURL"`
+}
+
+type mpdSegmentURL struct {
+	Media string `xml:"media,attr"`
+}
+
+type mpdTimeline struct {
+	S []struct {
+		T *int64 `xml:"t,attr"`
+		D int64  `xml:"d,attr"`
+		R int64  `xml:"r,attr"`
+	} `xml:"S"`
+}
+
+// ISO 8601 durations, eg: PT1H2M3.5S
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:([\d.]+)S)?)?$`)
+
+// parse an ISO 8601 duration
+func parseISODuration(s string) (time.Duration, error) {
+	m := isoDuration.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("Invalid duration: %q", s)
+	}
+	var d float64
+	for i, unit := range []float64{24 * 3600, 3600, 60, 1} {
+		if m[i+1] != "" {
+			n, _ := strconv.ParseFloat(m[i+1], 64)
+			d += n * unit
+		}
+	}
+	return time.Duration(d * float64(time.Second)), nil
+}
+
+// parse an MPD
+func parseMPD(data []byte) (*mpd, error) {
+	var m mpd
+	if err := xml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("Invalid DASH manifest: %s", err)
+	}
+	if len(m.Periods) == 0 {
+		return nil, errors.New("Invalid DASH manifest: no periods")
+	}
+	return &m, nil
+}
+
+// a representation chosen for recording, with its adaptation set
+type mpdStream struct {
+	set *mpdAdaptationSet
+	rep *mpdRepresentation
+}
+
+// the media type of a representation: video, audio or text
+func (s mpdStream) kind() string {
+	t := s.set.ContentType
+	if t == "" {
+		mime := s.rep.MimeType
+		if mime == "" {
+			mime = s.set.MimeType
+		}
+		t = strings.SplitN(mime, "/", 2)[0]
+	}
+	return t
+}
+
+// pick the best representation of a kind in a period: the highest
+// resolution, then the highest bandwidth
+func (p *mpdPeriod) best(kind string) *mpdStream {
+	var best *mpdStream
+	for i := range p.AdaptationSets {
+		set := &p.AdaptationSets[i]
+		for j := range set.Representations {
+			s := mpdStream{set, &set.Representations[j]}
+			if s.kind() != kind {
+				continue
+			}
+			if best == nil || s.rep.Height > best.rep.Height ||
+				(s.rep.Height == best.rep.Height && s.rep.Bandwidth > best.rep.Bandwidth) {
+				best = &s
+			}
+		}
+	}
+	return best
+}
+
+// find a representation by id
+func (p *mpdPeriod) find(id string) *mpdStream {
+	for i := range p.AdaptationSets {
+		set := &p.AdaptationSets[i]
+		for j := range set.Representations {
+			if set.Representations[j].Id == id {
+				return &mpdStream{set, &set.Representations[j]}
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the initialization segment and media segments of a representation.
+// Segments of a dynamic (live) presentation are those available at now.
+func (m *mpd) segments(p *mpdPeriod, s *mpdStream, manifest *url.URL, now time.Time) (string, []segment, error) {
+	// resolve the base URL down to the representation
+	base := manifest
+	for _, ref := range []string{m.BaseURL, p.BaseURL, s.set.BaseURL, s.rep.BaseURL} {
+		if ref == "" {
+			continue
+		}
+		u, err := url.Parse(ref)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid base url: %s", err)
+		}
+		base = base.ResolveReference(u)
+	}
+
+	list := s.rep.SegmentList
+	if list == nil {
+		list = s.set.SegmentList
+	}
+	tmpl := s.rep.SegmentTemplate
+	if tmpl == nil {
+		tmpl = s.set.SegmentTemplate
+	}
+
+	switch {
+	case list != nil:
+		var init string
+		if list.Initialization != nil {
+			init = resolveURL(base, list.Initialization.SourceURL)
+		}
+		start := int64(1)
+		if list.StartNumber != nil {
+			start = *list.StartNumber
+		}
+		segs := make([]segment, len(list.SegmentURLs))
+		for i, su := range list.SegmentURLs {
+			segs[i] = segment{start + int64(i), resolveURL(base, su.Media)}
+		}
+		return init, segs, nil
+
+	case tmpl != nil:
+		return m.templateSegments(p, s, tmpl, base, now)
+	}
+
+	// a single file
+	return "", []segment{{0, base.String()}}, nil
+}
+
+// the segments of a SegmentTemplate, listed in a timeline or numbered by
+// duration
+func (m *mpd) templateSegments(p *mpdPeriod, s *mpdStream, tmpl *mpdSegmentTemplate, base *url.URL, now time.Time) (string, []segment, error) {
+	timescale := tmpl.Timescale
+	if timescale == 0 {
+		timescale = 1
+	}
+	start := int64(1)
+	if tmpl.StartNumber != nil {
+		start = *tmpl.StartNumber
+	}
+
+	expand := func(number, t int64) string {
+		return resolveURL(base, expandTemplate(tmpl.Media, s.rep, number, t))
+	}
+	var init string
+	if tmpl.Initialization != "" {
+		init = resolveURL(base, expandTemplate(tmpl.Initialization, s.rep, 0, 0))
+	}
+
+	var segs []segment
+	if tmpl.Timeline != nil {
+		number, t := start, int64(0)
+		for _, e := range tmpl.Timeline.S {
+			if e.T != nil {
+				t = *e.T
+			}
+			for r := int64(0); r <= e.R; r++ {
+				segs = append(segs, segment{number, expand(number, t)})
+				number++
+				t += e.D
+			}
+		}
+		return init, segs, nil
+	}
+
+	if tmpl.Duration <= 0 {
+		return "", nil, errors.New("Unsupported DASH segment template")
+	}
+	segDuration := float64(tmpl.Duration) / float64(timescale)
+
+	first, last := start, start
+	if m.Type == "dynamic" {
+		// segments available now, within the time shift window
+		ast, err := time.Parse(time.RFC3339, m.AvailabilityStartTime)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid availability start time: %q", m.AvailabilityStartTime)
+		}
+		if p.Start != "" {
+			offset, err := parseISODuration(p.Start)
+			if err != nil {
+				return "", nil, err
+			}
+			ast = ast.Add(offset)
+		}
+		elapsed := now.Sub(ast).Seconds()
+		last = start + int64(elapsed/segDuration) - 1
+		first = start
+		if depth, err := parseISODuration(m.TimeShiftBufferDepth); err == nil && depth > 0 {
+			if f := last - int64(depth.Seconds()/segDuration) + 1; f > first {
+				first = f
+			}
+		}
+	} else {
+		total, err := parseISODuration(m.MediaPresentationDuration)
+		if err != nil {
+			return "", nil, err
+		}
+		last = start + int64(math.Ceil(total.Seconds()/segDuration)) - 1
+	}
+
+	for n := first; n <= last; n++ {
+		segs = append(segs, segment{n, expand(n, int64(float64(n-start)*float64(tmpl.Duration)))})
+	}
+	return init, segs, nil
+}
+
+// identifiers in segment templates: $Number$, $Number%05d$, $Time$ …
+var templateIdentifier = regexp.MustCompile(`\$(RepresentationID|Number|Bandwidth|Time)(%0\d+d)?\$|\$\$`)
+
+// expand the identifiers of a segment template
+func expandTemplate(tmpl string, rep *mpdRepresentation, number, t int64) string {
+	return templateIdentifier.ReplaceAllStringFunc(tmpl, func(id string) string {
+		if id == "$$" {
+			return "$"
+		}
+		m := templateIdentifier.FindStringSubmatch(id)
+		format := m[2]
+		if format == "" {
+			format = "%d"
+		}
+		switch m[1] {
+		case "RepresentationID":
+			return rep.Id
+		case "Number":
+			return fmt.Sprintf(format, number)
+		case "Bandwidth":
+			return fmt.Sprintf(format, rep.Bandwidth)
+		}
+		return fmt.Sprintf(format, t)
+	})
+}
+
+// fetch and parse an MPD
+func (c *Client) fetchMPD(ctx context.Context, manifest string) (*mpd, error) {
+	data, err := c.fetchSegment(ctx, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to fetch DASH manifest: %s", err)
+	}
+	return parseMPD(data)
+}
+
+// record the best video and audio representations of a DASH stream
+func (v *Video) recordDASH(ctx context.Context, filename string, option *LiveOption) error {
+	manifest, err := url.Parse(v.DASHManifestURL)
+	if err != nil {
+		return fmt.Errorf("Invalid DASH manifest url: %s", err)
+	}
+	m, err := v.client.fetchMPD(ctx, v.DASHManifestURL)
+	if err != nil {
+		return err
+	}
+
+	// a track for each of the best video and audio representations
+	period := &m.Periods[len(m.Periods)-1]
+	ext := filepath.Ext(filename)
+	var (
+		ids    []string
+		tracks []*track
+	)
+	for _, kind := range []string{"video", "audio"} {
+		s := period.best(kind)
+		if s == nil {
+			continue
+		}
+		name := filename
+		if kind == "audio" && len(tracks) > 0 {
+			name = strings.TrimSuffix(filename, ext) + ".audio" + ext
+		}
+		t, err := newTrack(name)
+		if err != nil {
+			// nothing has been recorded yet, remove the other track
+			for _, t := range tracks {
+				t.out.Close()
+				os.Remove(t.name)
+			}
+			return err
+		}
+		ids = append(ids, s.rep.Id)
+		tracks = append(tracks, t)
+	}
+	if len(tracks) == 0 {
+		return errors.New("No video or audio in DASH manifest")
+	}
+	v.client.log(&option.Option).Info("Recording", "file", filename)
+
+	fromStart := option.FromStart || m.Type != "dynamic"
+record:
+	for {
+		period = &m.Periods[len(m.Periods)-1]
+		for i, t := range tracks {
+			s := period.find(ids[i])
+			if s == nil {
+				err = fmt.Errorf("Representation %s is gone from the DASH manifest", ids[i])
+				break record
+			}
+			init, segs, serr := m.segments(period, s, manifest, time.Now())
+			if serr != nil {
+				err = serr
+				break record
+			}
+			if err = t.writeInit(ctx, v.client, init); err != nil {
+				break record
+			}
+			if err = t.writeSegments(ctx, v.client, segs, fromStart, option); err != nil {
+				break record
+			}
+		}
+		if m.Type != "dynamic" {
+			break
+		}
+
+		wait := 5 * time.Second
+		if d, err := parseISODuration(m.MinimumUpdatePeriod); err == nil && d > time.Second {
+			wait = d
+		}
+		if !sleepContext(ctx, wait) {
+			break
+		}
+		if m, err = v.client.fetchMPD(ctx, v.DASHManifestURL); err != nil {
+			if ctx.Err() != nil {
+				err = nil
+			}
+			break
+		}
+	}
+
+	if ctx.Err() != nil {
+		v.client.log(&option.Option).Info("Stopped recording")
+	}
+	for i := len(tracks) - 1; i >= 0; i-- {
+		// the first track is the video's file
+		if ferr := tracks[i].finish(v); err == nil {
+			err = ferr
+		}
+	}
+	if err != nil || len(tracks) < 2 {
+		return err
+	}
+	return v.mergeTracks(tracks[0].name, tracks[1].name, option)
+}
+
+// merge separately recorded video and audio with ffmpeg, if available
+func (v *Video) mergeTracks(video, audio string, option *LiveOption) error {
+	log := v.client.log(&option.Option)
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		log.Warn("ffmpeg not found, audio not merged", "file", audio)
+		return nil
+	}
+
+	log.Info("Merging audio")
+	ext := filepath.Ext(video)
+	merged := strings.TrimSuffix(video, ext) + ".merged" + ext
+	cmd := exec.Command(ffmpeg, "-y", "-loglevel", "quiet", "-i", video, "-i", audio, "-c", "copy", merged)
+	start := time.Now()
+	defer func() {
+		v.client.metrics().PostProcessed("merge", time.Since(start))
+	}()
+	if err := cmd.Run(); err != nil {
+		os.Remove(merged)
+		log.Warn("Failed to merge audio", "error", err)
+		return nil
+	}
+	if err := os.Rename(merged, video); err != nil {
+		return fmt.Errorf("Unable to rename %q: %s", merged, err)
+	}
+	os.Remove(audio)
+
+	// the download is now the merged file
+	info, err := os.Stat(video)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	if err := hashFile(video, hash, info.Size()); err != nil {
+		return fmt.Errorf("Unable to read file %q: %s", video, err)
+	}
+	v.DownloadInfo.Size = info.Size()
+	v.DownloadInfo.Sha256 = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
@@ -0,0 +1,150 @@
+package youtube
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// the ID of the video in testdata/player_response.json
+const testVideoID = "testvideo01"
+
+// a fake Youtube serving the metadata in testdata/player_response.json,
+// media for its formats with range requests, and captions.
+// Requests to any host are sent to it.
+type fakeYoutube struct {
+	*httptest.Server
+
+	// content of every format, unless there's a stream for its itag
+	media    []byte
+	streams  map[string][]byte
+	modified time.Time
+
+	mu       sync.Mutex
+	requests []string // method, path and Range header of media requests
+
+	// HEAD and GET media requests fail with these statuses, if set
+	headStatus int
+	getStatus  int
+	// HEAD requests have no Content-Length
+	noLength bool
+	// GET requests ignore the Range header
+	noRange bool
+	// GET requests end this many bytes early, without an error
+	short int
+}
+
+func newFakeYoutube(t *testing.T) *fakeYoutube {
+	t.Helper()
+	f := &fakeYoutube{
+		media:    testMedia(307200),
+		streams:  make(map[string][]byte),
+		modified: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/get_video_info", f.videoInfo)
+	mux.HandleFunc("/videoplayback", f.videoplayback)
+	mux.HandleFunc("/api/timedtext", f.timedtext)
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+// deterministic content, that differs at every offset of a 251 byte cycle
+func testMedia(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+// send requests for any host to the fake server
+func (f *fakeYoutube) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, _ := url.Parse(f.URL)
+	r := req.Clone(req.Context())
+	r.URL.Scheme = u.Scheme
+	r.URL.Host = u.Host
+	r.Host = u.Host
+	return f.Client().Transport.RoundTrip(r)
+}
+
+// Returns a client of the fake server, that doesn't log or cache
+func (f *fakeYoutube) client() *Client {
+	return &Client{
+		HTTP:   &http.Client{Transport: f},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// Returns the media requests so far, eg: "GET /videoplayback bytes=100-"
+func (f *fakeYoutube) mediaRequests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests...)
+}
+
+func (f *fakeYoutube) videoInfo(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("video_id") != testVideoID {
+		fmt.Fprint(w, "status=fail&errorcode=100&reason=Video+unavailable")
+		return
+	}
+	data, err := ioutil.ReadFile("testdata/player_response.json")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	q := url.Values{"status": {"ok"}, "player_response": {string(data)}}
+	fmt.Fprint(w, q.Encode())
+}
+
+func (f *fakeYoutube) videoplayback(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, r.Header.Get("Range")))
+	f.mu.Unlock()
+
+	switch {
+	case r.Method == "HEAD" && f.headStatus != 0:
+		w.WriteHeader(f.headStatus)
+		return
+	case r.Method == "GET" && f.getStatus != 0:
+		w.WriteHeader(f.getStatus)
+		return
+	case f.noLength && r.Method == "HEAD":
+		w.WriteHeader(http.StatusOK)
+		return
+	case r.Method == "GET" && f.short > 0:
+		w.Write(f.media[:len(f.media)-f.short])
+		return
+	case f.noRange:
+		r.Header.Del("Range")
+	}
+	media := f.media
+	if stream, ok := f.streams[r.URL.Query().Get("itag")]; ok {
+		media = stream
+	}
+	http.ServeContent(w, r, "", f.modified, bytes.NewReader(media))
+}
+
+func (f *fakeYoutube) timedtext(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("fmt") != "vtt" {
+		http.Error(w, "unsupported format", http.StatusBadRequest)
+		return
+	}
+	lang := q.Get("lang")
+	if tlang := q.Get("tlang"); tlang != "" {
+		lang = tlang
+	}
+	w.Header().Set("Content-Type", "text/vtt")
+	fmt.Fprintf(w, "WEBVTT\n\n00:00:00.000 --> 00:00:02.000\nHello (%s)\n", lang)
+}
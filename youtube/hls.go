@@ -0,0 +1,210 @@
+package youtube
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// an HLS (m3u8) playlist: either a master playlist of variant streams, or a
+// media playlist of segments
+type hlsPlaylist struct {
+	variants []hlsVariant
+
+	targetDuration time.Duration
+	segments       []segment
+	init           string // EXT-X-MAP initialization segment
+	ended          bool   // EXT-X-ENDLIST, the stream is over
+}
+
+// a variant stream in a master playlist
+type hlsVariant struct {
+	url       string
+	bandwidth int
+	height    int
+}
+
+// parse an m3u8 playlist, resolving URLs against base
+func parseHLS(data []byte, base *url.URL) (*hlsPlaylist, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "#EXTM3U" {
+		return nil, errors.New("Invalid HLS playlist")
+	}
+
+	var (
+		p       hlsPlaylist
+		seq     int64
+		variant *hlsVariant
+		pending bool // an EXTINF awaiting its URL
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		tag, value := line, ""
+		if i := strings.IndexByte(line, ':'); i > 0 && line[0] == '#' {
+			tag, value = line[:i], line[i+1:]
+		}
+
+		switch {
+		case line == "":
+		case tag == "#EXT-X-STREAM-INF":
+			attrs := parseHLSAttributes(value)
+			variant = &hlsVariant{}
+			variant.bandwidth, _ = strconv.Atoi(attrs["BANDWIDTH"])
+			if res := strings.SplitN(attrs["RESOLUTION"], "x", 2); len(res) == 2 {
+				variant.height, _ = strconv.Atoi(res[1])
+			}
+		case tag == "#EXT-X-TARGETDURATION":
+			secs, _ := strconv.ParseFloat(value, 64)
+			p.targetDuration = time.Duration(secs * float64(time.Second))
+		case tag == "#EXT-X-MEDIA-SEQUENCE":
+			seq, _ = strconv.ParseInt(value, 10, 64)
+		case tag == "#EXT-X-MAP":
+			p.init = resolveURL(base, parseHLSAttributes(value)["URI"])
+		case tag == "#EXT-X-KEY":
+			if method := parseHLSAttributes(value)["METHOD"]; method != "NONE" {
+				return nil, fmt.Errorf("Unsupported HLS encryption: %s", method)
+			}
+		case tag == "#EXTINF":
+			pending = true
+		case tag == "#EXT-X-ENDLIST":
+			p.ended = true
+		case line[0] == '#':
+			// other tags and comments
+		case variant != nil:
+			variant.url = resolveURL(base, line)
+			p.variants = append(p.variants, *variant)
+			variant = nil
+		case pending:
+			p.segments = append(p.segments, segment{seq, resolveURL(base, line)})
+			seq++
+			pending = false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Invalid HLS playlist: %s", err)
+	}
+	return &p, nil
+}
+
+// parse an attribute list such as BANDWIDTH=1280000,CODECS="avc1,mp4a"
+func parseHLSAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else if comma := strings.IndexByte(s, ','); comma >= 0 {
+			value, s = s[:comma], s[comma:]
+		} else {
+			value, s = s, ""
+		}
+		attrs[key] = value
+		s = strings.TrimPrefix(s, ",")
+	}
+	return attrs
+}
+
+// resolve a URL relative to a manifest
+func resolveURL(base *url.URL, ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil || base == nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+// fetch and parse an HLS playlist
+func (c *Client) fetchHLS(ctx context.Context, manifest string) (*hlsPlaylist, error) {
+	base, err := url.Parse(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid HLS playlist url: %s", err)
+	}
+	data, err := c.fetchSegment(ctx, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to fetch HLS playlist: %s", err)
+	}
+	return parseHLS(data, base)
+}
+
+// record the best variant of an HLS stream
+func (v *Video) recordHLS(ctx context.Context, filename string, option *LiveOption) error {
+	playlist, err := v.client.fetchHLS(ctx, v.HLSManifestURL)
+	if err != nil {
+		return err
+	}
+
+	// pick the variant with the highest resolution and bandwidth
+	media := v.HLSManifestURL
+	if len(playlist.variants) > 0 {
+		best := playlist.variants[0]
+		for _, vr := range playlist.variants[1:] {
+			if vr.height > best.height || (vr.height == best.height && vr.bandwidth > best.bandwidth) {
+				best = vr
+			}
+		}
+		media = best.url
+		if playlist, err = v.client.fetchHLS(ctx, media); err != nil {
+			return err
+		}
+	}
+
+	t, err := newTrack(filename)
+	if err != nil {
+		return err
+	}
+	v.client.log(&option.Option).Info("Recording", "file", filename)
+
+	for {
+		if err = t.writeInit(ctx, v.client, playlist.init); err != nil {
+			break
+		}
+		if err = t.writeSegments(ctx, v.client, playlist.segments, option.FromStart || playlist.ended, option); err != nil {
+			break
+		}
+		if playlist.ended {
+			break
+		}
+
+		// refresh the playlist once per target duration
+		wait := playlist.targetDuration
+		if wait <= 0 {
+			wait = 5 * time.Second
+		}
+		if !sleepContext(ctx, wait) {
+			break
+		}
+		if playlist, err = v.client.fetchHLS(ctx, media); err != nil {
+			if ctx.Err() != nil {
+				err = nil
+			}
+			break
+		}
+	}
+
+	if ctx.Err() != nil {
+		v.client.log(&option.Option).Info("Stopped recording")
+	}
+	if ferr := t.finish(v); err == nil {
+		err = ferr
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package youtube
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a caption (subtitle) track
+type Caption struct {
+	Url            string `json:"url"`
+	Name           string `json:"name"`
+	LanguageCode   string `json:"language_code"`
+	Kind           string `json:"kind"` // "asr" for auto-generated captions
+	IsTranslatable bool   `json:"is_translatable"`
+}
+
+// a chapter marker, in seconds from the start of the video
+type Chapter struct {
+	Title string `json:"title"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// facts about a finished download
+type DownloadInfo struct {
+	Itag      int       `json:"itag"`
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size"`
+	Sha256    string    `json:"sha256"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// the part of the format downloaded by DownloadSection, or nil
+	Section *Section `json:"section,omitempty"`
+}
+
+var (
+	// chapter lines: "0:00 Intro", "(1:02:03) - Outro", "Outro - 1:02:03"
+	chapterStart = regexp.MustCompile(`^\W*?((?:\d+:)?\d{1,2}:\d{2})\)?\s*[-–—:|]?\s*(.+)$`)
+	chapterEnd   = regexp.MustCompile(`^(.+?)\s*[-–—:|]?\s*\(?((?:\d+:)?\d{1,2}:\d{2})\)?$`)
+)
+
+// Writes the video's metadata, including the last download's facts,
+// to filename as JSON
+func (v *Video) WriteInfoJSON(filename string) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("Unable to write to file %q: %s", filename, err)
+	}
+	return nil
+}
+
+// Rebuilds a Video from a file written by WriteInfoJSON. Format urls
+// expire after a few hours, after which the video has to be fetched again.
+func LoadInfoJSON(filename string) (Video, error) {
+	var video Video
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return video, err
+	}
+
+	if err := json.Unmarshal(data, &video); err != nil {
+		return video, fmt.Errorf("Invalid info file %q: %s", filename, err)
+	}
+	return video, nil
+}
+
+// parse chapters from timestamps in a video description. Like Youtube, only
+// lists that start at 0:00 and have at least three ascending timestamps count.
+func parseChapters(description string, length int) []Chapter {
+	var chapters []Chapter
+
+	for _, line := range strings.Split(description, "\n") {
+		line = strings.TrimSpace(line)
+
+		var stamp, title string
+		if m := chapterStart.FindStringSubmatch(line); m != nil {
+			stamp, title = m[1], m[2]
+		} else if m := chapterEnd.FindStringSubmatch(line); m != nil {
+			stamp, title = m[2], m[1]
+		} else {
+			continue
+		}
+
+		start := parseTimestamp(stamp)
+		if len(chapters) == 0 && start != 0 {
+			continue
+		}
+		if n := len(chapters); n > 0 && start <= chapters[n-1].Start {
+			return nil
+		}
+		chapters = append(chapters, Chapter{Title: strings.TrimSpace(title), Start: start})
+	}
+
+	if len(chapters) < 3 {
+		return nil
+	}
+
+	for i := range chapters {
+		if i < len(chapters)-1 {
+			chapters[i].End = chapters[i+1].Start
+		} else {
+			chapters[i].End = length
+		}
+	}
+	return chapters
+}
+
+// convert a [h:]mm:ss timestamp to seconds
+func parseTimestamp(stamp string) int {
+	secs := 0
+	for _, p := range strings.Split(stamp, ":") {
+		n, _ := strconv.Atoi(p)
+		secs = secs*60 + n
+	}
+	return secs
+}
+
+// record the facts of a finished download
+func (v *Video) setDownloadInfo(index int, size int64, h hash.Hash) {
+	v.DownloadInfo = &DownloadInfo{
+		Itag:      v.Formats[index].Itag,
+		Filename:  v.Filename,
+		Size:      size,
+		Sha256:    hex.EncodeToString(h.Sum(nil)),
+		Timestamp: time.Now(),
+	}
+}
+
+// feed the first n bytes of a file to a hash
+func hashFile(filename string, h hash.Hash, n int64) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.CopyN(h, f, n)
+	return err
+}
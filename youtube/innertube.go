@@ -0,0 +1,160 @@
+package youtube
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Youtube web pages and InnerTube (the web client's internal API)
+	URL_PLAYLIST = "https://www.youtube.com/playlist?list="
+	URL_BROWSE   = "https://www.youtube.com/youtubei/v1/browse?prettyPrint=false"
+
+	INNERTUBE_CLIENT_NAME    = "WEB"
+	INNERTUBE_CLIENT_VERSION = "2.20240101.00.00"
+)
+
+// markers preceding the initial data JSON embedded in Youtube pages
+var initialDataMarkers = []string{
+	"var ytInitialData = ",
+	`window["ytInitialData"] = `,
+}
+
+// InnerTube text, sent either as a simple string or as formatted runs
+type text struct {
+	SimpleText string `json:"simpleText"`
+	Runs       []struct {
+		Text string `json:"text"`
+	} `json:"runs"`
+}
+
+func (t text) String() string {
+	if t.SimpleText != "" {
+		return t.SimpleText
+	}
+	var s strings.Builder
+	for _, r := range t.Runs {
+		s.WriteString(r.Text)
+	}
+	return s.String()
+}
+
+// a pointer to the next page of a paginated list
+type continuationItem struct {
+	ContinuationEndpoint struct {
+		ContinuationCommand struct {
+			Token string `json:"token"`
+		} `json:"continuationCommand"`
+	} `json:"continuationEndpoint"`
+}
+
+// response to a browse continuation request
+type continuationResponse struct {
+	OnResponseReceivedActions []struct {
+		AppendContinuationItemsAction struct {
+			ContinuationItems json.RawMessage `json:"continuationItems"`
+		} `json:"appendContinuationItemsAction"`
+		ReloadContinuationItemsCommand struct {
+			ContinuationItems json.RawMessage `json:"continuationItems"`
+		} `json:"reloadContinuationItemsCommand"`
+	} `json:"onResponseReceivedActions"`
+}
+
+// fetch a Youtube web page
+func (c *Client) fetchPage(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// relative dates and counts are parsed in English
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Request failed: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// extract the initial data JSON embedded in a Youtube page
+func extractInitialData(page []byte) ([]byte, error) {
+	for _, marker := range initialDataMarkers {
+		i := bytes.Index(page, []byte(marker))
+		if i < 0 {
+			continue
+		}
+
+		// decode a single JSON value, ignoring the script that follows it
+		var data json.RawMessage
+		dec := json.NewDecoder(bytes.NewReader(page[i+len(marker):]))
+		if err := dec.Decode(&data); err != nil {
+			return nil, fmt.Errorf("Invalid initial data: %s", err)
+		}
+		return data, nil
+	}
+	return nil, errors.New("No initial data found in page")
+}
+
+// fetch the next page of a paginated list
+func (c *Client) browse(token string) ([]byte, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"context": map[string]interface{}{
+			"client": map[string]string{
+				"clientName":    INNERTUBE_CLIENT_NAME,
+				"clientVersion": INNERTUBE_CLIENT_VERSION,
+				"hl":            "en",
+			},
+		},
+		"continuation": token,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", URL_BROWSE, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// logged-in sessions sign their requests
+	c.authorize(req)
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Browse request failed: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// extract the items appended by a continuation response
+func parseContinuation(data []byte) (json.RawMessage, error) {
+	var resp continuationResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("Invalid continuation response: %s", err)
+	}
+
+	for _, a := range resp.OnResponseReceivedActions {
+		if items := a.AppendContinuationItemsAction.ContinuationItems; items != nil {
+			return items, nil
+		}
+		if items := a.ReloadContinuationItemsCommand.ContinuationItems; items != nil {
+			return items, nil
+		}
+	}
+	return nil, nil
+}
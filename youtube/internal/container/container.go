@@ -0,0 +1,208 @@
+// Package container inspects MP4 (ISO-BMFF) and WebM (Matroska) files
+// without external tools: their duration, tracks and codecs, and the index
+// of their segments. Files are read through an io.ReaderAt, so they may be
+// local files or byte ranges of remote ones.
+package container
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Wrapped by the errors of files that end early
+var ErrTruncated = errors.New("Truncated")
+
+// Returned for files that are neither MP4 nor WebM
+var ErrUnknown = errors.New("Unknown container format")
+
+// the largest box or element read into memory, eg: a moov box or Cues
+const maxElementSize = 64 << 20
+
+// the structure of a file
+type Info struct {
+	Format   string        `json:"format"` // mp4, webm or mkv
+	Brand    string        `json:"brand"`  // major brand of MP4 files, DocType of WebM files
+	Duration time.Duration `json:"duration"`
+	Tracks   []Track       `json:"tracks"`
+
+	// MP4 files with movie fragments, eg: DASH streams
+	Fragmented bool `json:"fragmented,omitempty"`
+
+	// the end of the initialization data: the moov box, or the elements
+	// before the first Cluster
+	InitEnd int64 `json:"init_end"`
+
+	// the sidx box or Cues, or nil
+	Index *Index `json:"index,omitempty"`
+
+	// the parts of the file that make up Header
+	header []byteRange
+
+	// the offset and length of a WebM Segment's size
+	segmentSize byteRange
+}
+
+// a track of a file
+type Track struct {
+	ID       uint64        `json:"id"`
+	Type     string        `json:"type"`  // video, audio, subtitle or the file's name for it
+	Codec    string        `json:"codec"` // eg: avc1, mp4a, V_VP9, A_OPUS
+	Language string        `json:"language,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+
+	Width      int `json:"width,omitempty"`
+	Height     int `json:"height,omitempty"`
+	SampleRate int `json:"sample_rate,omitempty"`
+	Channels   int `json:"channels,omitempty"`
+}
+
+// the index of the segments of a file, from a sidx box or Cues
+type Index struct {
+	// the position of the sidx box or Cues in the file
+	Offset int64 `json:"offset"`
+	Size   int64 `json:"size"`
+
+	Segments []Segment `json:"segments"`
+}
+
+// an independently decodable part of a file, eg: a movie fragment or a
+// Cluster
+type Segment struct {
+	Offset   int64         `json:"offset"`
+	Size     int64         `json:"size"`
+	Start    time.Duration `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+type byteRange struct {
+	offset, length int64
+}
+
+// Reads the structure of a whole file of size bytes, checking that none of
+// its boxes or elements are truncated
+func Probe(r io.ReaderAt, size int64) (*Info, error) {
+	return probe(r, size, true)
+}
+
+// Reads the initialization data and index at the start of a file, without
+// reading through the rest. Use it for byte ranges of remote files.
+func ProbeHeader(r io.ReaderAt, size int64) (*Info, error) {
+	return probe(r, size, false)
+}
+
+func probe(r io.ReaderAt, size int64, full bool) (*Info, error) {
+	f := &file{r: r, size: size}
+	head, err := f.read(0, min64(size, 64))
+	if err != nil {
+		return nil, err
+	}
+	switch Detect(head) {
+	case "mp4", "3gp":
+		return probeMP4(f, full)
+	case "webm", "mkv":
+		return probeWebM(f, full)
+	}
+	return nil, ErrUnknown
+}
+
+// Returns the container format of a file from its first bytes: mp4, 3gp,
+// webm, mkv, flv or ts, or "" if it's unknown
+func Detect(head []byte) string {
+	switch {
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		if bytes.HasPrefix(head[8:12], []byte("3g")) {
+			return "3gp"
+		}
+		return "mp4"
+	case bytes.HasPrefix(head, ebmlMagic):
+		if docType(head) == "matroska" {
+			return "mkv"
+		}
+		return "webm"
+	case bytes.HasPrefix(head, []byte("FLV\x01")):
+		return "flv"
+	case len(head) > 188 && head[0] == 0x47 && head[188] == 0x47:
+		return "ts"
+	}
+	return ""
+}
+
+// Returns the initialization data to play the segments of the index on
+// their own, eg: to cut a section of a file: the ftyp and moov boxes of MP4
+// files, and the header of WebM files with a Segment of unknown size,
+// without the index
+func (i *Info) Header(r io.ReaderAt) ([]byte, error) {
+	var b bytes.Buffer
+	for _, h := range i.header {
+		data := make([]byte, h.length)
+		if _, err := r.ReadAt(data, h.offset); err != nil {
+			return nil, err
+		}
+
+		// the segment's size changes, so it's set to unknown: all ones
+		if s := i.segmentSize; s.length > 0 && h.offset <= s.offset && s.offset+s.length <= h.offset+h.length {
+			size := data[s.offset-h.offset : s.offset-h.offset+s.length]
+			size[0] = 0xff >> uint(s.length-1)
+			for j := 1; j < len(size); j++ {
+				size[j] = 0xff
+			}
+		}
+		b.Write(data)
+	}
+	return b.Bytes(), nil
+}
+
+// Returns the segments of the index that overlap start to end, or to the
+// end of the file if end is 0
+func (x *Index) Section(start, end time.Duration) []Segment {
+	var section []Segment
+	for _, s := range x.Segments {
+		if s.Start+s.Duration <= start {
+			continue
+		}
+		if end > 0 && s.Start >= end {
+			break
+		}
+		section = append(section, s)
+	}
+	return section
+}
+
+// a file being probed
+type file struct {
+	r    io.ReaderAt
+	size int64
+}
+
+// read n bytes at offset
+func (f *file) read(offset, n int64) ([]byte, error) {
+	if offset+n > f.size {
+		return nil, fmt.Errorf("%w data at offset %d", ErrTruncated, offset)
+	}
+	if n > maxElementSize {
+		return nil, fmt.Errorf("Element at offset %d is too large: %d bytes", offset, n)
+	}
+	data := make([]byte, n)
+	if m, err := f.r.ReadAt(data, offset); m < len(data) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// convert a duration in units of a timescale
+func duration(units, timescale uint64) time.Duration {
+	if timescale == 0 {
+		return 0
+	}
+	return time.Duration(units/timescale)*time.Second + time.Duration(units%timescale)*time.Second/time.Duration(timescale)
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
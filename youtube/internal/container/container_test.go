@@ -0,0 +1,306 @@
+package container
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// an MP4 box
+func box(boxType string, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	b := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint32(b, uint32(8+len(data)))
+	copy(b[4:], boxType)
+	return append(b, data...)
+}
+
+// big-endian integers of n bytes
+func be(n int, values ...uint64) []byte {
+	var b []byte
+	for _, v := range values {
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, v)
+		b = append(b, buf[8-n:]...)
+	}
+	return b
+}
+
+// a fragmented MP4 file with a video and an audio track, and three
+// segments of 3 seconds
+func testMP4() (file []byte, init []byte, segments []Segment) {
+	ftyp := box("ftyp", []byte("dash"), be(4, 0), []byte("iso6avc1"))
+	tkhd := box("tkhd", be(4, 0, 0, 0, 1, 0, 0), make([]byte, 52), be(4, 1280<<16, 720<<16))
+	visual := box("avc1", make([]byte, 6), be(2, 1), make([]byte, 16), be(2, 1280, 720), make([]byte, 50))
+	video := box("trak", tkhd, box("mdia",
+		box("mdhd", be(4, 0, 0, 0, 15360, 0), be(2, 0x55c4, 0)), // und
+		box("hdlr", be(4, 0, 0), []byte("vide"), make([]byte, 13)),
+		box("minf", box("stbl", box("stsd", be(4, 0, 1), visual)))))
+	sound := box("mp4a", make([]byte, 6), be(2, 1), make([]byte, 8), be(2, 2, 16), be(4, 0, 44100<<16))
+	audio := box("trak", box("tkhd", be(4, 0, 0, 0, 2, 0, 0), make([]byte, 60)), box("mdia",
+		box("mdhd", be(4, 1<<24, 0, 0, 0, 0, 44100), be(8, 0), be(2, 0x15c7, 0)), // version 1, eng
+		box("hdlr", be(4, 0, 0), []byte("soun"), make([]byte, 13)),
+		box("minf", box("stbl", box("stsd", be(4, 0, 1), sound)))))
+	moov := box("moov",
+		box("mvhd", be(4, 0, 0, 0, 1000, 0), make([]byte, 80)),
+		video, audio,
+		box("mvex", box("mehd", be(4, 0, 9000))))
+
+	var media [][]byte
+	for i := 0; i < 3; i++ {
+		media = append(media, append(box("moof", make([]byte, 20+i)), box("mdat", make([]byte, 100*(i+1)))...))
+	}
+	refs := []byte{}
+	for _, m := range media {
+		refs = append(refs, be(4, uint64(len(m)), 3000, 0x90000000)...)
+	}
+	sidx := box("sidx", be(4, 0, 1, 1000, 0, 0), be(2, 0, 3), refs)
+
+	init = append(ftyp, moov...)
+	file = append(append([]byte{}, init...), sidx...)
+	for i, m := range media {
+		segments = append(segments, Segment{Offset: int64(len(file)), Size: int64(len(m)), Start: time.Duration(i) * 3 * time.Second, Duration: 3 * time.Second})
+		file = append(file, m...)
+	}
+	return file, init, segments
+}
+
+// an EBML element
+func element(id uint64, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	return append(append(vintBytes(id), size(uint64(len(data)))...), data...)
+}
+
+// an EBML size of 1 to 8 bytes
+func size(n uint64) []byte {
+	for length := 1; length <= 8; length++ {
+		if n < 1<<(7*uint(length))-1 {
+			b := be(length, n)
+			b[0] |= 0x80 >> uint(length-1)
+			return b
+		}
+	}
+	panic("size too large")
+}
+
+func float(f float64) []byte {
+	return be(8, math.Float64bits(f))
+}
+
+// a WebM file with a video and audio track, and three clusters of 3
+// seconds. With cuesLast, the Cues are after the clusters.
+func testWebM(cuesLast bool) (file []byte, header []byte, segments []Segment) {
+	ebml := element(idEBML, element(idDocType, []byte("webm")))
+	info := element(idInfo, element(idScale, be(3, 1000000)), element(idDuration, float(9000)))
+	tracks := element(idTracks,
+		element(idEntry, element(idNumber, be(1, 1)), element(idType, be(1, 1)), element(idCodec, []byte("V_VP9")),
+			element(idVideo, element(idWidth, be(2, 1280)), element(idHeight, be(2, 720)))),
+		element(idEntry, element(idNumber, be(1, 2)), element(idType, be(1, 2)), element(idCodec, []byte("A_OPUS")),
+			element(idLanguage, []byte("ger")), element(idAudio, element(idRate, float(48000)), element(idChannels, be(1, 2)))))
+	var clusters [][]byte
+	for i := 0; i < 3; i++ {
+		clusters = append(clusters, element(idCluster, make([]byte, 100*(i+1))))
+	}
+
+	// positions are relative to the segment's data, after a SeekHead of
+	// a fixed size
+	cues := func(first uint64) []byte {
+		var points [][]byte
+		pos := first
+		for i, c := range clusters {
+			points = append(points, element(idCuePoint, element(idCueTime, be(2, uint64(i)*3000)),
+				element(idCuePos, element(0xf7, be(1, 1)), element(idPosition, be(4, pos)))))
+			pos += uint64(len(c))
+		}
+		return element(idCues, points...)
+	}
+	seekHead := func(pos uint64) []byte {
+		return element(idSeekHead, element(idSeek, element(idSeekID, vintBytes(idCues)), element(idSeekPos, be(4, pos))))
+	}
+	headLen := uint64(len(seekHead(0)) + len(info) + len(tracks))
+	cuesLen := uint64(len(cues(0)))
+
+	var body []byte
+	first := headLen + cuesLen
+	if cuesLast {
+		first = headLen
+	}
+	if cuesLast {
+		body = bytes.Join([][]byte{seekHead(headLen + uint64(len(bytes.Join(clusters, nil)))), info, tracks, bytes.Join(clusters, nil), cues(first)}, nil)
+	} else {
+		body = bytes.Join([][]byte{seekHead(headLen), info, tracks, cues(first), bytes.Join(clusters, nil)}, nil)
+	}
+	segment := append(append(vintBytes(idSegment), 0x01, 0, 0, 0, 0, 0, 0, 0), body...)
+	binary.BigEndian.PutUint32(segment[8:], uint32(len(body)))
+
+	file = append(ebml, segment...)
+	header = bytes.Join([][]byte{ebml, vintBytes(idSegment), {0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, info, tracks}, nil)
+	offset := int64(len(ebml)+12) + int64(first)
+	for i, c := range clusters {
+		segments = append(segments, Segment{Offset: offset, Size: int64(len(c)), Start: time.Duration(i) * 3 * time.Second, Duration: 3 * time.Second})
+		offset += int64(len(c))
+	}
+	return file, header, segments
+}
+
+// a reader that fails reads past a limit
+type limitedReader struct {
+	data  []byte
+	limit int64
+}
+
+func (r *limitedReader) ReadAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > r.limit {
+		return 0, fmt.Errorf("read of %d bytes at %d past the limit", len(p), off)
+	}
+	return bytes.NewReader(r.data).ReadAt(p, off)
+}
+
+func TestProbeMP4(t *testing.T) {
+	data, init, segments := testMP4()
+	info, err := Probe(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Format != "mp4" || info.Brand != "dash" || !info.Fragmented || info.Duration != 9*time.Second {
+		t.Errorf("info = %+v", info)
+	}
+	if info.InitEnd != int64(len(init)) {
+		t.Errorf("InitEnd = %d, want %d", info.InitEnd, len(init))
+	}
+	tracks := []Track{
+		{ID: 1, Type: "video", Codec: "avc1", Language: "und", Width: 1280, Height: 720},
+		{ID: 2, Type: "audio", Codec: "mp4a", Language: "eng", SampleRate: 44100, Channels: 2},
+	}
+	if !reflect.DeepEqual(info.Tracks, tracks) {
+		t.Errorf("tracks = %+v, want %+v", info.Tracks, tracks)
+	}
+	if info.Index == nil || info.Index.Offset != int64(len(init)) || !reflect.DeepEqual(info.Index.Segments, segments) {
+		t.Fatalf("index = %+v, want segments %+v", info.Index, segments)
+	}
+
+	header, err := info.Header(bytes.NewReader(data))
+	if err != nil || !bytes.Equal(header, init) {
+		t.Errorf("header = %d bytes (%v), want the ftyp and moov boxes", len(header), err)
+	}
+
+	// only the header is read
+	head, err := ProbeHeader(&limitedReader{data, segments[0].Offset + 8}, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(head.Index.Segments, segments) || head.Duration != info.Duration {
+		t.Errorf("header index = %+v, want %+v", head.Index, segments)
+	}
+}
+
+func TestProbeWebM(t *testing.T) {
+	for _, cuesLast := range []bool{false, true} {
+		data, header, segments := testWebM(cuesLast)
+		info, err := Probe(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Format != "webm" || info.Brand != "webm" || info.Duration != 9*time.Second {
+			t.Errorf("info = %+v", info)
+		}
+		tracks := []Track{
+			{ID: 1, Type: "video", Codec: "V_VP9", Language: "eng", Width: 1280, Height: 720},
+			{ID: 2, Type: "audio", Codec: "A_OPUS", Language: "ger", SampleRate: 48000, Channels: 2},
+		}
+		if !reflect.DeepEqual(info.Tracks, tracks) {
+			t.Errorf("tracks = %+v, want %+v", info.Tracks, tracks)
+		}
+		if info.InitEnd != segments[0].Offset {
+			t.Errorf("InitEnd = %d, want %d", info.InitEnd, segments[0].Offset)
+		}
+		if info.Index == nil || !reflect.DeepEqual(info.Index.Segments, segments) {
+			t.Fatalf("cues last %v: index = %+v, want segments %+v", cuesLast, info.Index, segments)
+		}
+
+		h, err := info.Header(bytes.NewReader(data))
+		if err != nil || !bytes.Equal(h, header) {
+			t.Errorf("header = %x (%v), want %x", h, err, header)
+		}
+
+		// the header and the Cues are read
+		r := &limitedReader{data, segments[0].Offset + 16}
+		if cuesLast {
+			r.limit = int64(len(data))
+		}
+		head, err := ProbeHeader(r, int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(head.Index.Segments, segments) {
+			t.Errorf("cues last %v: header index = %+v, want %+v", cuesLast, head.Index, segments)
+		}
+	}
+}
+
+func TestProbeTruncated(t *testing.T) {
+	mp4, _, _ := testMP4()
+	webm, _, _ := testWebM(false)
+	for _, data := range [][]byte{mp4, webm} {
+		for _, cut := range []int{1, 10, 200, len(data) - 12} {
+			short := data[:len(data)-cut]
+			if _, err := Probe(bytes.NewReader(short), int64(len(short))); !errors.Is(err, ErrTruncated) {
+				t.Errorf("%s cut by %d: got error %v, want a truncation", Detect(data), cut, err)
+			}
+		}
+	}
+
+	if _, err := Probe(bytes.NewReader([]byte("plain text")), 10); err != ErrUnknown {
+		t.Errorf("got error %v for an unknown format", err)
+	}
+}
+
+func TestSection(t *testing.T) {
+	_, _, segments := testMP4()
+	index := &Index{Segments: segments}
+	tests := []struct {
+		start, end time.Duration
+		want       []Segment
+	}{
+		{0, 0, segments},
+		{3 * time.Second, 6 * time.Second, segments[1:2]},
+		{4 * time.Second, 7 * time.Second, segments[1:3]},
+		{8 * time.Second, 0, segments[2:]},
+		{10 * time.Second, 0, nil},
+	}
+	for _, test := range tests {
+		if got := index.Section(test.start, test.end); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Section(%s, %s) = %+v, want %+v", test.start, test.end, got, test.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	mp4, _, _ := testMP4()
+	webm, _, _ := testWebM(false)
+	mkv := element(idEBML, element(idDocType, []byte("matroska")))
+	ts := make([]byte, 376)
+	ts[0], ts[188] = 0x47, 0x47
+
+	tests := map[string][]byte{
+		"mp4":  mp4[:64],
+		"3gp":  box("ftyp", []byte("3gp4"), be(4, 0)),
+		"webm": webm[:64],
+		"mkv":  mkv,
+		"flv":  []byte("FLV\x01\x05"),
+		"ts":   ts,
+		"":     []byte("<html>"),
+	}
+	for want, head := range tests {
+		if got := Detect(head); got != want {
+			t.Errorf("Detect(%q...) = %q, want %q", head[:4], got, want)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package container
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// read the structure of an MP4 file: its top-level boxes, the movie box and
+// the first segment index. Unless full, it stops at the media data after
+// the movie box.
+func probeMP4(f *file, full bool) (*Info, error) {
+	info := &Info{Format: "mp4"}
+	moov := false
+	var mehd uint64
+	var timescale uint64
+
+	var offset int64
+	for offset < f.size {
+		boxType, headerSize, boxSize, err := f.boxHeader(offset)
+		if err != nil {
+			return nil, err
+		}
+		if offset+boxSize > f.size {
+			return nil, fmt.Errorf("%w %s box: %d of %d bytes", ErrTruncated, boxType, f.size-offset, boxSize)
+		}
+		payload := func() ([]byte, error) {
+			return f.read(offset+headerSize, boxSize-headerSize)
+		}
+
+		switch boxType {
+		case "ftyp":
+			data, err := payload()
+			if err != nil {
+				return nil, err
+			}
+			if len(data) >= 4 {
+				info.Brand = string(data[:4])
+			}
+			if len(data) >= 4 && data[0] == '3' && data[1] == 'g' {
+				info.Format = "3gp"
+			}
+			info.header = append(info.header, byteRange{offset, boxSize})
+
+		case "moov":
+			data, err := payload()
+			if err != nil {
+				return nil, err
+			}
+			if timescale, mehd, err = parseMoov(info, data); err != nil {
+				return nil, err
+			}
+			moov = true
+			info.header = append(info.header, byteRange{offset, boxSize})
+			info.InitEnd = offset + boxSize
+
+		case "sidx":
+			if info.Index != nil {
+				break
+			}
+			data, err := payload()
+			if err != nil {
+				return nil, err
+			}
+			if info.Index, err = parseSidx(data, offset+boxSize); err != nil {
+				return nil, err
+			}
+			info.Index.Offset, info.Index.Size = offset, boxSize
+
+		case "moof", "mdat":
+			if boxType == "moof" {
+				info.Fragmented = true
+			}
+			if moov && !full {
+				return finishMP4(info, mehd, timescale), nil
+			}
+		}
+		offset += boxSize
+	}
+
+	if !moov {
+		return nil, errors.New("No moov box")
+	}
+	return finishMP4(info, mehd, timescale), nil
+}
+
+// fill in the duration of fragmented files, which is missing from their
+// movie header
+func finishMP4(info *Info, mehd, timescale uint64) *Info {
+	if info.Duration == 0 && mehd > 0 {
+		info.Duration = duration(mehd, timescale)
+	}
+	if info.Duration == 0 && info.Index != nil {
+		for _, s := range info.Index.Segments {
+			info.Duration += s.Duration
+		}
+	}
+	return info
+}
+
+// read the type, header size and size of the box at offset
+func (f *file) boxHeader(offset int64) (string, int64, int64, error) {
+	if f.size-offset < 8 {
+		return "", 0, 0, fmt.Errorf("%w box header at offset %d", ErrTruncated, offset)
+	}
+	header, err := f.read(offset, 8)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	boxType := string(header[4:8])
+	headerSize := int64(8)
+	boxSize := int64(binary.BigEndian.Uint32(header))
+	switch boxSize {
+	case 0:
+		// the last box, to the end of the file
+		boxSize = f.size - offset
+	case 1:
+		// a 64-bit size after the type
+		if f.size-offset < 16 {
+			return "", 0, 0, fmt.Errorf("%w %s box header at offset %d", ErrTruncated, boxType, offset)
+		}
+		large, err := f.read(offset+8, 8)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		boxSize = int64(binary.BigEndian.Uint64(large))
+		headerSize = 16
+	}
+	if boxSize < headerSize {
+		return "", 0, 0, fmt.Errorf("Invalid %s box size %d at offset %d", boxType, boxSize, offset)
+	}
+	return boxType, headerSize, boxSize, nil
+}
+
+// call fn with the type and payload of each box in data
+func boxes(data []byte, fn func(boxType string, payload []byte) error) error {
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return errors.New("Invalid box header")
+		}
+		size := uint64(binary.BigEndian.Uint32(data))
+		boxType := string(data[4:8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return fmt.Errorf("Invalid %s box header", boxType)
+			}
+			size = binary.BigEndian.Uint64(data[8:])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return fmt.Errorf("Invalid %s box size %d", boxType, size)
+		}
+		if err := fn(boxType, data[header:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// a big-endian reader of box fields, which reads zeros past the end of the
+// box
+type fields []byte
+
+func (b fields) uint(offset, n int) uint64 {
+	if offset < 0 || offset+n > len(b) {
+		return 0
+	}
+	var v uint64
+	for _, c := range b[offset : offset+n] {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// a field of 4 bytes in version 0 boxes and 8 in version 1 ones
+func (b fields) versioned(offset0, offset1 int) uint64 {
+	if b.uint(0, 1) == 1 {
+		return b.uint(offset1, 8)
+	}
+	return b.uint(offset0, 4)
+}
+
+// parse a movie box. Returns the movie's timescale and fragment duration.
+func parseMoov(info *Info, data []byte) (timescale, fragmentDuration uint64, err error) {
+	err = boxes(data, func(boxType string, payload []byte) error {
+		b := fields(payload)
+		switch boxType {
+		case "mvhd":
+			if b.uint(0, 1) == 1 {
+				timescale = b.uint(20, 4)
+				info.Duration = duration(b.uint(24, 8), timescale)
+			} else {
+				timescale = b.uint(12, 4)
+				info.Duration = duration(b.uint(16, 4), timescale)
+			}
+		case "trak":
+			t, err := parseTrak(payload, Track{})
+			if err != nil {
+				return err
+			}
+			info.Tracks = append(info.Tracks, t)
+		case "mvex":
+			info.Fragmented = true
+			return boxes(payload, func(boxType string, payload []byte) error {
+				if boxType == "mehd" {
+					fragmentDuration = fields(payload).versioned(4, 4)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return timescale, fragmentDuration, err
+}
+
+// handler types of tracks
+var handlers = map[string]string{
+	"vide": "video",
+	"soun": "audio",
+	"text": "subtitle",
+	"subt": "subtitle",
+	"sbtl": "subtitle",
+}
+
+// parse a track box, or one of its containers into a track
+func parseTrak(data []byte, t Track) (Track, error) {
+	err := boxes(data, func(boxType string, payload []byte) error {
+		b := fields(payload)
+		switch boxType {
+		case "tkhd":
+			if b.uint(0, 1) == 1 {
+				t.ID = b.uint(20, 4)
+			} else {
+				t.ID = b.uint(12, 4)
+			}
+			// 16.16 fixed point, at the end
+			t.Width = int(b.uint(len(b)-8, 4) >> 16)
+			t.Height = int(b.uint(len(b)-4, 4) >> 16)
+		case "mdia", "minf", "stbl":
+			// containers of the boxes below
+			var err error
+			t, err = parseTrak(payload, t)
+			return err
+		case "mdhd":
+			var timescale uint64
+			var lang uint64
+			if b.uint(0, 1) == 1 {
+				timescale = b.uint(20, 4)
+				t.Duration = duration(b.uint(24, 8), timescale)
+				lang = b.uint(32, 2)
+			} else {
+				timescale = b.uint(12, 4)
+				t.Duration = duration(b.uint(16, 4), timescale)
+				lang = b.uint(20, 2)
+			}
+			t.Language = language(lang)
+		case "hdlr":
+			handler := string(payload[min(8, len(payload)):min(12, len(payload))])
+			if name, ok := handlers[handler]; ok {
+				t.Type = name
+			} else {
+				t.Type = handler
+			}
+		case "stsd":
+			// the first sample entry, after the version and entry count
+			if len(payload) < 16 {
+				return errors.New("Invalid stsd box")
+			}
+			entry := payload[8:]
+			size := binary.BigEndian.Uint32(entry)
+			if size < 8 || int(size) > len(entry) {
+				return errors.New("Invalid stsd box")
+			}
+			t.Codec = string(entry[4:8])
+			sample := fields(entry[8:size])
+			if t.Type == "audio" {
+				t.Channels = int(sample.uint(16, 2))
+				t.SampleRate = int(sample.uint(24, 4) >> 16)
+			} else if t.Type == "video" || t.Width == 0 {
+				if w, h := int(sample.uint(24, 2)), int(sample.uint(26, 2)); w > 0 && h > 0 {
+					t.Width, t.Height = w, h
+				}
+			}
+		}
+		return nil
+	})
+	return t, err
+}
+
+// an ISO 639-2 language code packed in 15 bits
+func language(packed uint64) string {
+	if packed == 0 {
+		return ""
+	}
+	code := []byte{
+		byte(packed>>10&0x1f) + 0x60,
+		byte(packed>>5&0x1f) + 0x60,
+		byte(packed&0x1f) + 0x60,
+	}
+	return string(code)
+}
+
+// parse a segment index box ending at end
+func parseSidx(data []byte, end int64) (*Index, error) {
+	b := fields(data)
+	timescale := b.uint(8, 4)
+	var start, first uint64
+	refs := 0
+	if b.uint(0, 1) == 1 {
+		start, first = b.uint(12, 8), b.uint(20, 8)
+		refs = 32
+	} else {
+		start, first = b.uint(12, 4), b.uint(16, 4)
+		refs = 24
+	}
+	count := int(b.uint(refs-2, 2))
+	if timescale == 0 || len(data) < refs+12*count {
+		return nil, errors.New("Invalid sidx box")
+	}
+
+	index := &Index{}
+	offset := end + int64(first)
+	for i := 0; i < count; i++ {
+		ref := refs + 12*i
+		size := int64(b.uint(ref, 4) & 0x7fffffff)
+		d := b.uint(ref+4, 4)
+		index.Segments = append(index.Segments, Segment{
+			Offset:   offset,
+			Size:     size,
+			Start:    duration(start, timescale),
+			Duration: duration(start+d, timescale) - duration(start, timescale),
+		})
+		offset += size
+		start += d
+	}
+	return index, nil
+}
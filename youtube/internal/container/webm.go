@@ -0,0 +1,429 @@
+package container
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// the ID of the EBML header, at the start of WebM files
+var ebmlMagic = []byte{0x1a, 0x45, 0xdf, 0xa3}
+
+// element IDs
+const (
+	idEBML     = 0x1a45dfa3
+	idDocType  = 0x4282
+	idSegment  = 0x18538067
+	idSeekHead = 0x114d9b74
+	idSeek     = 0x4dbb
+	idSeekID   = 0x53ab
+	idSeekPos  = 0x53ac
+	idInfo     = 0x1549a966
+	idScale    = 0x2ad7b1
+	idDuration = 0x4489
+	idTracks   = 0x1654ae6b
+	idEntry    = 0xae
+	idNumber   = 0xd7
+	idType     = 0x83
+	idCodec    = 0x86
+	idLanguage = 0x22b59c
+	idBCP47    = 0x22b59d
+	idVideo    = 0xe0
+	idWidth    = 0xb0
+	idHeight   = 0xba
+	idAudio    = 0xe1
+	idRate     = 0xb5
+	idChannels = 0x9f
+	idCues     = 0x1c53bb6b
+	idCuePoint = 0xbb
+	idCueTime  = 0xb3
+	idCuePos   = 0xb7
+	idCluster  = 0x1f43b675
+	idVoid     = 0xec
+	idPosition = 0xf1
+)
+
+// Matroska track types
+var trackTypes = map[uint64]string{1: "video", 2: "audio", 17: "subtitle"}
+
+// a cue point: a time and the position of its Cluster in the Segment
+type cue struct {
+	time, position uint64
+}
+
+// read the structure of a WebM file: its EBML header, the Segment and its
+// top-level elements. Unless full, it stops at the first Cluster, after
+// reading the Cues if the SeekHead points past it.
+func probeWebM(f *file, full bool) (*Info, error) {
+	info := &Info{Format: "webm"}
+
+	// the EBML header
+	id, n, size, err := f.elementHeader(0)
+	if err != nil {
+		return nil, err
+	}
+	if id != idEBML || size < 0 {
+		return nil, errors.New("Invalid EBML header")
+	}
+	offset := n + size
+	if offset > f.size {
+		return nil, fmt.Errorf("%w EBML header", ErrTruncated)
+	}
+	data, err := f.read(n, size)
+	if err != nil {
+		return nil, err
+	}
+	info.Brand = string(child(data, idDocType))
+	if info.Brand == "matroska" {
+		info.Format = "mkv"
+	}
+	info.header = append(info.header, byteRange{0, offset})
+
+	// the segment, of unknown size in live recordings
+	id, n, size, err = f.elementHeader(offset)
+	if err != nil {
+		return nil, err
+	}
+	if id != idSegment {
+		return nil, fmt.Errorf("No Segment after the EBML header, found element %x", id)
+	}
+	idLen := int64(len(vintBytes(id)))
+	info.segmentSize = byteRange{offset + idLen, n - idLen}
+	info.header = append(info.header, byteRange{offset, n})
+	start := offset + n
+	end := f.size
+	if size >= 0 {
+		end = start + size
+		if end > f.size {
+			return nil, fmt.Errorf("%w Segment: %d of %d bytes", ErrTruncated, f.size-start, size)
+		}
+	}
+
+	scale := uint64(1000000)
+	var cues []cue
+	var cuesAt int64 = -1
+	for offset = start; offset < end; {
+		id, n, size, err := f.elementHeader(offset)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			// a Cluster of unknown size, the rest is media
+			break
+		}
+		if offset+n+size > end {
+			return nil, fmt.Errorf("%w element %x at offset %d", ErrTruncated, id, offset)
+		}
+
+		element := func() ([]byte, error) {
+			return f.read(offset+n, size)
+		}
+		switch id {
+		case idSeekHead:
+			data, err := element()
+			if err != nil {
+				return nil, err
+			}
+			if pos := seekPosition(data, idCues); pos >= 0 {
+				cuesAt = start + pos
+			}
+		case idInfo:
+			data, err := element()
+			if err != nil {
+				return nil, err
+			}
+			if s := uintValue(child(data, idScale)); s > 0 {
+				scale = s
+			}
+			info.Duration = floatDuration(child(data, idDuration), scale)
+			info.header = append(info.header, byteRange{offset, n + size})
+		case idTracks:
+			data, err := element()
+			if err != nil {
+				return nil, err
+			}
+			if info.Tracks, err = parseTracks(data); err != nil {
+				return nil, err
+			}
+			info.header = append(info.header, byteRange{offset, n + size})
+		case idCues:
+			if cues != nil {
+				break
+			}
+			data, err := element()
+			if err != nil {
+				return nil, err
+			}
+			info.Index = &Index{Offset: offset, Size: n + size}
+			if cues, err = parseCues(data); err != nil {
+				return nil, err
+			}
+		case idCluster:
+			if info.InitEnd == 0 {
+				info.InitEnd = offset
+			}
+		case idVoid:
+		default:
+			// other elements before the media, eg: Tags
+			if info.InitEnd == 0 {
+				info.header = append(info.header, byteRange{offset, n + size})
+			}
+		}
+
+		if id == idCluster && !full {
+			break
+		}
+		offset += n + size
+	}
+
+	// the Cues after the Clusters
+	if info.Index == nil && cuesAt > offset && cuesAt < end {
+		_, n, size, err := f.elementHeader(cuesAt)
+		if err != nil {
+			return nil, err
+		}
+		data, err := f.read(cuesAt+n, size)
+		if err != nil {
+			return nil, err
+		}
+		info.Index = &Index{Offset: cuesAt, Size: n + size}
+		if cues, err = parseCues(data); err != nil {
+			return nil, err
+		}
+	}
+	if info.Index != nil {
+		// the last segment ends at the Cues after it
+		last := end
+		for _, c := range cues {
+			if info.Index.Offset > start+int64(c.position) {
+				last = info.Index.Offset
+			}
+		}
+		info.Index.Segments = cueSegments(cues, start, last, scale, info.Duration)
+	}
+	return info, nil
+}
+
+// the segments between cue points
+func cueSegments(cues []cue, start, end int64, scale uint64, total time.Duration) []Segment {
+	sort.Slice(cues, func(i, j int) bool { return cues[i].position < cues[j].position })
+	var segments []Segment
+	for i, c := range cues {
+		if i > 0 && c.position == cues[i-1].position {
+			continue
+		}
+		s := Segment{
+			Offset: start + int64(c.position),
+			Start:  time.Duration(c.time * scale),
+		}
+		if n := len(segments); n > 0 {
+			last := &segments[n-1]
+			last.Size = s.Offset - last.Offset
+			last.Duration = s.Start - last.Start
+		}
+		segments = append(segments, s)
+	}
+	if n := len(segments); n > 0 {
+		last := &segments[n-1]
+		last.Size = end - last.Offset
+		if total > last.Start {
+			last.Duration = total - last.Start
+		}
+	}
+	return segments
+}
+
+// Returns the DocType in the EBML header at the start of a file, eg: webm
+func docType(head []byte) string {
+	id, idLen, ok := vint(head, false)
+	if !ok || id != idEBML {
+		return ""
+	}
+	size, sizeLen, ok := vint(head[idLen:], true)
+	data := head[idLen+sizeLen:]
+	if !ok || size > uint64(len(data)) {
+		return ""
+	}
+	return string(child(data[:size], idDocType))
+}
+
+// parse the entries of Tracks
+func parseTracks(data []byte) ([]Track, error) {
+	var tracks []Track
+	err := elements(data, func(id uint64, entry []byte) error {
+		if id != idEntry {
+			return nil
+		}
+		t := Track{
+			ID:       uintValue(child(entry, idNumber)),
+			Codec:    string(child(entry, idCodec)),
+			Language: string(child(entry, idLanguage)),
+		}
+		if lang := child(entry, idBCP47); lang != nil {
+			t.Language = string(lang)
+		} else if t.Language == "" {
+			t.Language = "eng"
+		}
+		kind := uintValue(child(entry, idType))
+		if t.Type = trackTypes[kind]; t.Type == "" {
+			t.Type = fmt.Sprint(kind)
+		}
+		if video := child(entry, idVideo); video != nil {
+			t.Width = int(uintValue(child(video, idWidth)))
+			t.Height = int(uintValue(child(video, idHeight)))
+		}
+		if audio := child(entry, idAudio); audio != nil {
+			t.SampleRate = int(floatValue(child(audio, idRate)))
+			t.Channels = int(uintValue(child(audio, idChannels)))
+			if t.Channels == 0 {
+				t.Channels = 1
+			}
+		}
+		tracks = append(tracks, t)
+		return nil
+	})
+	return tracks, err
+}
+
+// parse the cue points of Cues
+func parseCues(data []byte) ([]cue, error) {
+	cues := []cue{}
+	err := elements(data, func(id uint64, point []byte) error {
+		if id != idCuePoint {
+			return nil
+		}
+		pos := child(point, idCuePos)
+		if pos == nil {
+			return errors.New("Invalid CuePoint")
+		}
+		cues = append(cues, cue{
+			time:     uintValue(child(point, idCueTime)),
+			position: uintValue(child(pos, idPosition)),
+		})
+		return nil
+	})
+	return cues, err
+}
+
+// the position of an element in a SeekHead, or -1
+func seekPosition(data []byte, target uint64) int64 {
+	pos := int64(-1)
+	elements(data, func(id uint64, seek []byte) error {
+		if id == idSeek && bytes.Equal(child(seek, idSeekID), vintBytes(target)) {
+			pos = int64(uintValue(child(seek, idSeekPos)))
+		}
+		return nil
+	})
+	return pos
+}
+
+// read an element's ID and data size at offset. Returns the length of the
+// header, and a size of -1 if it's unknown.
+func (f *file) elementHeader(offset int64) (id uint64, n int64, size int64, err error) {
+	head, err := f.read(offset, min64(16, f.size-offset))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	id, idLen, ok := vint(head, false)
+	if ok {
+		var s uint64
+		var sizeLen int
+		if s, sizeLen, ok = vint(head[idLen:], true); ok {
+			size = int64(s)
+			if s == 1<<(7*uint(sizeLen))-1 {
+				size = -1
+			}
+			return id, int64(idLen + sizeLen), size, nil
+		}
+	}
+	if len(head) < 16 {
+		return 0, 0, 0, fmt.Errorf("%w element at offset %d", ErrTruncated, offset)
+	}
+	return 0, 0, 0, fmt.Errorf("Invalid element at offset %d", offset)
+}
+
+// call fn with the ID and data of each element in data
+func elements(data []byte, fn func(id uint64, data []byte) error) error {
+	for len(data) > 0 {
+		id, idLen, ok := vint(data, false)
+		if !ok {
+			return errors.New("Invalid element")
+		}
+		size, sizeLen, ok := vint(data[idLen:], true)
+		header := uint64(idLen + sizeLen)
+		if !ok || size > uint64(len(data))-header {
+			return fmt.Errorf("Invalid element %x", id)
+		}
+		if err := fn(id, data[header:header+size]); err != nil {
+			return err
+		}
+		data = data[header+size:]
+	}
+	return nil
+}
+
+// Returns the data of the first child element with an ID, or nil
+func child(data []byte, target uint64) []byte {
+	var found []byte
+	elements(data, func(id uint64, data []byte) error {
+		if id == target && found == nil {
+			found = data
+		}
+		return nil
+	})
+	return found
+}
+
+// read a variable length integer. IDs keep their length marker, sizes
+// don't.
+func vint(data []byte, stripMarker bool) (uint64, int, bool) {
+	if len(data) == 0 {
+		return 0, 0, false
+	}
+	length := 1
+	for mask := byte(0x80); length <= 8 && data[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 || length > len(data) {
+		return 0, 0, false
+	}
+
+	v := uint64(data[0])
+	if stripMarker {
+		v &= uint64(0xff >> uint(length))
+	}
+	for _, c := range data[1:length] {
+		v = v<<8 | uint64(c)
+	}
+	return v, length, true
+}
+
+// the bytes of an element ID
+func vintBytes(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return bytes.TrimLeft(b, "\x00")
+}
+
+func uintValue(data []byte) uint64 {
+	return fields(data).uint(0, len(data))
+}
+
+func floatValue(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
+
+// a float duration in units of a timecode scale, in nanoseconds
+func floatDuration(data []byte, scale uint64) time.Duration {
+	return time.Duration(floatValue(data) * float64(scale))
+}
@@ -0,0 +1,151 @@
+// Package replay records HTTP responses to fixture files and replays them,
+// so that tests run the same requests offline.
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// Mode of a Transport
+type Mode int
+
+const (
+	Replay Mode = iota // serve responses from fixtures, failing unknown requests
+	Record             // send requests and store their responses as fixtures
+)
+
+// An http.RoundTripper that records responses to, or replays them from, a
+// directory of fixture files, one JSON file per request. Requests are told
+// apart by their method, URL and Range header.
+type Transport struct {
+	Dir  string
+	Mode Mode
+
+	// the transport requests are sent with when recording, or
+	// http.DefaultTransport
+	Next http.RoundTripper
+}
+
+// a recorded response
+type fixture struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Range  string      `json:"range,omitempty"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+
+	// the body as text, or base64 encoded if it isn't UTF-8
+	Body   string `json:"body"`
+	Base64 bool   `json:"base64,omitempty"`
+}
+
+// headers that aren't recorded
+var skipHeaders = []string{"Set-Cookie", "Date"}
+
+// Returns a transport replaying the fixtures in dir
+func New(dir string) *Transport {
+	return &Transport{Dir: dir}
+}
+
+// Returns the fixture file of a request
+func (t *Transport) Path(req *http.Request) string {
+	key := req.Method + " " + req.URL.String() + " " + req.Header.Get("Range")
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(t.Dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Mode == Record {
+		return t.record(req)
+	}
+	return t.replay(req)
+}
+
+func (t *Transport) replay(req *http.Request) (*http.Response, error) {
+	path := t.Path(req)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("No fixture for %s %s", req.Method, req.URL)
+	} else if err != nil {
+		return nil, fmt.Errorf("Unable to read fixture %q: %s", path, err)
+	}
+
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("Invalid fixture %q: %s", path, err)
+	}
+	body := []byte(f.Body)
+	if f.Base64 {
+		if body, err = base64.StdEncoding.DecodeString(f.Body); err != nil {
+			return nil, fmt.Errorf("Invalid fixture %q: %s", path, err)
+		}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        f.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (t *Transport) record(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	f := fixture{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Range:  req.Header.Get("Range"),
+		Status: resp.StatusCode,
+		Header: resp.Header.Clone(),
+		Body:   string(body),
+	}
+	for _, h := range skipHeaders {
+		f.Header.Del(h)
+	}
+	if !utf8.Valid(body) {
+		f.Body = base64.StdEncoding.EncodeToString(body)
+		f.Base64 = true
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(t.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("Unable to create directory %q: %s", t.Dir, err)
+	}
+	path := t.Path(req)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("Unable to write to file %q: %s", path, err)
+	}
+	return resp, nil
+}
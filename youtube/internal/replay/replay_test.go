@@ -0,0 +1,77 @@
+package replay
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordReplay(t *testing.T) {
+	binary := []byte{0, 1, 2, 0xff, 0xfe}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		switch r.URL.Path {
+		case "/text":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("hello"))
+		case "/binary":
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(binary))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	dir := t.TempDir()
+	rec := &http.Client{Transport: &Transport{Dir: dir, Mode: Record}}
+	requests := []struct {
+		path, rng string
+		status    int
+		body      string
+	}{
+		{"/text", "", 200, "hello"},
+		{"/binary", "", 200, string(binary)},
+		{"/binary", "bytes=3-", 206, string(binary[3:])},
+		{"/missing", "", 404, "404 page not found\n"},
+	}
+	get := func(c *http.Client, path, rng string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		if rng != "" {
+			req.Header.Set("Range", rng)
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %s", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+	for _, r := range requests {
+		if _, body := get(rec, r.path, r.rng); body != r.body {
+			t.Errorf("recording GET %s %s: got body %q, want %q", r.path, r.rng, body, r.body)
+		}
+	}
+
+	// replay without the server
+	srv.Close()
+	rep := &http.Client{Transport: New(dir)}
+	for _, r := range requests {
+		resp, body := get(rep, r.path, r.rng)
+		if resp.StatusCode != r.status || body != r.body {
+			t.Errorf("replaying GET %s %s: got %d %q, want %d %q", r.path, r.rng, resp.StatusCode, body, r.status, r.body)
+		}
+		if resp.Header.Get("Set-Cookie") != "" {
+			t.Errorf("replaying GET %s: cookie was recorded", r.path)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", srv.URL+"/unknown", nil)
+	if _, err := rep.Do(req); err == nil || !strings.Contains(err.Error(), "No fixture") {
+		t.Errorf("replaying an unknown request: got %v, want a missing fixture error", err)
+	}
+}
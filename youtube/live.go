@@ -0,0 +1,278 @@
+package youtube
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// how often an upcoming stream is checked once its scheduled start has passed
+const LIVE_POLL_INTERVAL = 30 * time.Second
+
+// Live recording options
+type LiveOption struct {
+	Option
+
+	// record from the start of the stream's DVR window instead of from now
+	FromStart bool
+
+	// wait for an upcoming stream or premiere to start, instead of failing
+	WaitUpcoming bool
+}
+
+// a media segment of a live stream
+type segment struct {
+	seq int64
+	url string
+}
+
+// a file a stream is recorded to
+type track struct {
+	name    string
+	out     *os.File
+	hash    hash.Hash
+	size    int64
+	last    int64 // sequence number of the last written segment
+	started bool
+	init    bool // initialization segment written
+}
+
+// Returns the file extension of a live recording: ts for HLS streams, mp4
+// for DASH streams
+func (v *Video) LiveExtension() string {
+	if v.HLSManifestURL == "" && v.DASHManifestURL != "" {
+		return "mp4"
+	}
+	return "ts"
+}
+
+// Records a live stream, or a finished stream from its manifest, to filename.
+// Recording stops when the stream ends, or cleanly when ctx is cancelled,
+// leaving a playable file of the whole segments downloaded so far. HLS
+// streams are recorded as MPEG-TS. DASH streams are recorded as fragmented
+// MP4, with the audio in a separate .audio.mp4 file unless ffmpeg is
+// available to merge them.
+func (v *Video) RecordLive(ctx context.Context, filename string, option *LiveOption) error {
+	if v.IsUpcoming {
+		if !option.WaitUpcoming {
+			if v.ScheduledStart.IsZero() {
+				return errors.New("Stream hasn't started yet")
+			}
+			return fmt.Errorf("Stream starts at %s", v.ScheduledStart.Local().Format("2006-01-02 15:04:05"))
+		}
+		if err := v.waitLive(ctx, option); err != nil {
+			return err
+		}
+	}
+
+	if v.HLSManifestURL == "" && v.DASHManifestURL == "" {
+		return errors.New("No live stream manifest available")
+	}
+
+	// create the output directory
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("Unable to create directory %q: %s", dir, err)
+		}
+	}
+
+	m := v.client.metrics()
+	m.DownloadStarted()
+	start := time.Now()
+	v.DownloadInfo = nil
+
+	var err error
+	if v.HLSManifestURL != "" {
+		err = v.recordHLS(ctx, filename, option)
+	} else {
+		err = v.recordDASH(ctx, filename, option)
+	}
+	var size int64
+	if v.DownloadInfo != nil {
+		size = v.DownloadInfo.Size
+	}
+	m.DownloadFinished(size, time.Since(start), err)
+	if err != nil {
+		return err
+	}
+
+	// a stopped recording is kept, but only a whole stream is archived
+	if ctx.Err() == nil {
+		v.archive(&option.Option)
+	}
+	return nil
+}
+
+// wait for an upcoming stream to start, refreshing the video's metadata
+func (v *Video) waitLive(ctx context.Context, option *LiveOption) error {
+	for v.IsUpcoming {
+		wait := LIVE_POLL_INTERVAL
+		if d := time.Until(v.ScheduledStart); d > wait {
+			wait = d
+			v.client.log(&option.Option).Info("Waiting for the stream to start", "at", v.ScheduledStart.Local().Format("2006-01-02 15:04:05"))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		if err := v.Refresh(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// create a track's output file
+func newTrack(filename string) (*track, error) {
+	out, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to write to file %q: %s", filename, err)
+	}
+	return &track{name: filename, out: out, hash: sha256.New()}, nil
+}
+
+// write a track's initialization segment, once
+func (t *track) writeInit(ctx context.Context, c *Client, url string) error {
+	if t.init || url == "" {
+		return nil
+	}
+	data, err := c.fetchSegment(ctx, url)
+	if err != nil {
+		return fmt.Errorf("Unable to fetch initialization segment: %s", err)
+	}
+	t.init = true
+	return t.write(data)
+}
+
+// write the segments after the last written one. Unless fromStart, a
+// track's first write starts at the newest segment.
+func (t *track) writeSegments(ctx context.Context, c *Client, segments []segment, fromStart bool, option *LiveOption) error {
+	if !t.started && len(segments) > 0 {
+		t.started = true
+		t.last = segments[0].seq - 1
+		if !fromStart {
+			t.last = segments[len(segments)-1].seq - 1
+		}
+	}
+
+	for _, s := range segments {
+		if s.seq <= t.last {
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		data, err := c.fetchSegment(ctx, s.url)
+		switch {
+		case ctx.Err() != nil:
+			// stopped, the partial segment isn't written
+			return nil
+		case err == errSegmentGone:
+			c.log(&option.Option).Warn("Segment expired, skipping", "segment", s.seq)
+			t.last = s.seq
+			continue
+		case err != nil:
+			return fmt.Errorf("Unable to fetch segment %d: %s", s.seq, err)
+		}
+
+		if err := t.write(data); err != nil {
+			return err
+		}
+		t.last = s.seq
+		c.log(&option.Option).Info("Segment", "segment", s.seq, "size", abbr(t.size))
+	}
+	return nil
+}
+
+func (t *track) write(data []byte) error {
+	if _, err := t.out.Write(data); err != nil {
+		return fmt.Errorf("Unable to write to file %q: %s", t.name, err)
+	}
+	t.hash.Write(data)
+	t.size += int64(len(data))
+	return nil
+}
+
+// close a track's file and record it as the video's download
+func (t *track) finish(v *Video) error {
+	if err := t.out.Close(); err != nil {
+		return fmt.Errorf("Unable to write to file %q: %s", t.name, err)
+	}
+	v.Filename = t.name
+	v.DownloadInfo = &DownloadInfo{
+		Filename:  t.name,
+		Size:      t.size,
+		Sha256:    hex.EncodeToString(t.hash.Sum(nil)),
+		Timestamp: time.Now(),
+	}
+	return nil
+}
+
+var errSegmentGone = errors.New("Segment is no longer available")
+
+// fetch a segment or manifest, retrying transient failures
+func (c *Client) fetchSegment(ctx context.Context, url string) ([]byte, error) {
+	var err error
+	for try := 0; try < 3; try++ {
+		if try > 0 {
+			c.metrics().Retry("segment")
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(try) * time.Second):
+			}
+		}
+
+		var data []byte
+		if data, err = c.fetch(ctx, url); err == nil {
+			return data, nil
+		}
+		if err == errSegmentGone || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// fetch a URL with a context
+func (c *Client) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return ioutil.ReadAll(resp.Body)
+	case http.StatusNotFound, http.StatusGone:
+		return nil, errSegmentGone
+	}
+	return nil, fmt.Errorf("Request failed: %s", resp.Status)
+}
+
+// sleep for a manifest's refresh interval. Returns false if ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -0,0 +1,127 @@
+package youtube
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// an archive of the videos added to it
+type testArchive struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (a *testArchive) Has(id string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, i := range a.ids {
+		if i == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (a *testArchive) Add(id string, itag int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ids = append(a.ids, id)
+	return nil
+}
+
+// a stream of two HLS segments. If the stream is live, stop is called when
+// the playlist is refreshed.
+func hlsServer(t *testing.T, ended bool, stop func()) *httptest.Server {
+	var refreshed bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/live.m3u8":
+			if refreshed {
+				stop()
+			}
+			refreshed = true
+			io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:0\n#EXTINF:1,\nseg0.ts\n#EXTINF:1,\nseg1.ts\n")
+			if ended {
+				io.WriteString(w, "#EXT-X-ENDLIST\n")
+			}
+		case "/seg0.ts", "/seg1.ts":
+			io.WriteString(w, strings.TrimSuffix(r.URL.Path[1:], ".ts"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func liveVideo(manifest string) *Video {
+	return &Video{
+		Id:             "live",
+		HLSManifestURL: manifest,
+		client:         &Client{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))},
+	}
+}
+
+func TestRecordLiveArchive(t *testing.T) {
+	for _, ended := range []bool{true, false} {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		srv := hlsServer(t, ended, cancel)
+
+		archive := &testArchive{}
+		filename := filepath.Join(t.TempDir(), "live.ts")
+		v := liveVideo(srv.URL + "/live.m3u8")
+		option := &LiveOption{Option: Option{Archive: archive}, FromStart: true}
+		if err := v.RecordLive(ctx, filename, option); err != nil {
+			t.Fatalf("ended %v: %s", ended, err)
+		}
+
+		// the recording is kept either way
+		data, err := os.ReadFile(filename)
+		if err != nil || string(data) != "seg0seg1" {
+			t.Errorf("ended %v: recorded %q, %v", ended, data, err)
+		}
+
+		// only a whole stream is archived
+		if archived, _ := archive.Has("live"); archived != ended {
+			t.Errorf("ended %v: archived %v", ended, archived)
+		}
+	}
+}
+
+func TestRecordDASHTrackFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<?xml version="1.0"?>
+<MPD type="static" mediaPresentationDuration="PT2S">
+  <Period>
+    <AdaptationSet contentType="video"><Representation id="v" bandwidth="1000" height="720"><BaseURL>v.mp4</BaseURL></Representation></AdaptationSet>
+    <AdaptationSet contentType="audio"><Representation id="a" bandwidth="100"><BaseURL>a.mp4</BaseURL></Representation></AdaptationSet>
+  </Period>
+</MPD>`)
+	}))
+	defer srv.Close()
+
+	// the audio track can't be created
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "live.mp4")
+	if err := os.Mkdir(filepath.Join(dir, "live.audio.mp4"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	v := liveVideo("")
+	v.DASHManifestURL = srv.URL + "/manifest.mpd"
+	if err := v.RecordLive(context.Background(), filename, &LiveOption{}); err == nil {
+		t.Fatal("recorded without an audio track")
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("the video track was left behind: %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package youtube
+
+import (
+	"os"
+	"syscall"
+)
+
+// lock a file for reading (shared) or writing (exclusive), waiting
+// for other processes to release it
+func lockFile(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	return syscall.Flock(int(f.Fd()), how)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,219 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// query parameters (also as /<name>/<value> path pairs, as in manifest
+// URLs) and headers left out of HTTP traces
+var (
+	redactedParams  = []string{"sig", "signature", "lsig", "n", "pot", "key"}
+	redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+)
+
+// Returns a handler that writes records as plain lines, eg:
+// "Resuming download offset=1024", prefixed by the level if it isn't
+// info. It's the default logger's handler, writing to stdout.
+func NewConsoleHandler(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+	if opts == nil {
+		opts = &slog.HandlerOptions{}
+	}
+	return &consoleHandler{w: w, level: opts.Level, mu: &sync.Mutex{}}
+}
+
+type consoleHandler struct {
+	w     io.Writer
+	level slog.Leveler
+	attrs string // preformatted attributes of With
+	group string
+	mu    *sync.Mutex
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.level != nil {
+		min = h.level.Level()
+	}
+	return level >= min
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if r.Level != slog.LevelInfo {
+		b.WriteString(r.Level.String() + ": ")
+	}
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		b.WriteString(h.format(a))
+		return true
+	})
+	b.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	for _, a := range attrs {
+		c.attrs += h.format(a)
+	}
+	return &c
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.group += name + "."
+	return &c
+}
+
+// format an attribute as " key=value", quoting values with spaces
+func (h *consoleHandler) format(a slog.Attr) string {
+	if a.Equal(slog.Attr{}) {
+		return ""
+	}
+	v := a.Value.Resolve().String()
+	if strings.ContainsAny(v, " \t\n\"=") || v == "" {
+		v = fmt.Sprintf("%q", v)
+	}
+	return " " + h.group + a.Key + "=" + v
+}
+
+// a handler that drops records below a level
+type levelHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+func (h levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level && h.Handler.Enabled(ctx, level)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{h.Handler.WithAttrs(attrs), h.level}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{h.Handler.WithGroup(name), h.level}
+}
+
+var defaultLogger = slog.New(NewConsoleHandler(os.Stdout, nil))
+
+// the client's logger, or the default one writing to stdout
+func (c *Client) logger() *slog.Logger {
+	if c == nil || c.Logger == nil {
+		return defaultLogger
+	}
+	return c.Logger
+}
+
+// the logger for a download's messages. Quiet drops status messages,
+// keeping warnings.
+func (c *Client) log(option *Option) *slog.Logger {
+	l := c.logger()
+	if option != nil && option.Quiet {
+		return slog.New(levelHandler{l.Handler(), slog.LevelWarn})
+	}
+	return l
+}
+
+// an http.RoundTripper that traces requests and responses at the debug
+// level, without signatures and cookies
+type tracingTransport struct {
+	next   http.RoundTripper
+	logger *slog.Logger
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	t.logger.DebugContext(ctx, "HTTP request",
+		"method", req.Method,
+		"url", redactURL(req.URL),
+		"headers", redactHeaders(req.Header))
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.logger.DebugContext(ctx, "HTTP request failed",
+			"method", req.Method,
+			"url", redactURL(req.URL),
+			"duration", time.Since(start),
+			"error", err)
+		return resp, err
+	}
+	t.logger.DebugContext(ctx, "HTTP response",
+		"method", req.Method,
+		"url", redactURL(req.URL),
+		"status", resp.StatusCode,
+		"length", resp.ContentLength,
+		"duration", time.Since(start),
+		"headers", redactHeaders(resp.Header))
+	return resp, nil
+}
+
+// Returns a URL without its credentials, signature and other secret
+// parameters
+func redactURL(u *url.URL) string {
+	r := *u
+	if r.User != nil {
+		r.User = url.User("REDACTED")
+	}
+	if r.RawQuery != "" {
+		q := r.Query()
+		for _, p := range redactedParams {
+			if _, ok := q[p]; ok {
+				q.Set(p, "REDACTED")
+			}
+		}
+		r.RawQuery = q.Encode()
+	}
+
+	// manifest and some media URLs carry parameters in the path, eg:
+	// /api/manifest/dash/id/x/sig/y
+	parts := strings.Split(r.Path, "/")
+	redacted := false
+	for i := 0; i+1 < len(parts); i++ {
+		for _, p := range redactedParams {
+			if parts[i] == p {
+				parts[i+1] = "REDACTED"
+				redacted = true
+				i++
+				break
+			}
+		}
+	}
+	if redacted {
+		r.Path, r.RawPath = strings.Join(parts, "/"), ""
+	}
+	return r.String()
+}
+
+// Returns headers as "Name: value" lines, without cookies and credentials
+func redactHeaders(h http.Header) string {
+	var lines []string
+	for name, values := range h {
+		for _, v := range values {
+			for _, r := range redactedHeaders {
+				if http.CanonicalHeaderKey(name) == r {
+					v = "REDACTED"
+				}
+			}
+			lines = append(lines, name+": "+v)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "; ")
+}
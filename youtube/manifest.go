@@ -0,0 +1,132 @@
+package youtube
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DASH namespace and profile of the manifests written by DASHManifest
+const (
+	DASH_NAMESPACE = "urn:mpeg:dash:schema:mpd:2011"
+	DASH_PROFILE   = "urn:mpeg:dash:profile:isoff-on-demand:2011"
+)
+
+// Returns a static DASH manifest (MPD) of the video's adaptive formats, so
+// that a player can stream any quality, with seeking, straight from
+// Youtube. There is one AdaptationSet per media type (eg: video/mp4,
+// audio/webm), and every format is a Representation whose SegmentBase byte
+// ranges point into its URL. Format URLs expire after a few hours.
+func (v *Video) DASHManifest() ([]byte, error) {
+	return v.DASHManifestFunc(nil)
+}
+
+// Returns a DASH manifest like DASHManifest, with the URL of each format
+// given by urlFunc, eg: the address of a local proxy. A nil urlFunc uses
+// the format URLs.
+func (v *Video) DASHManifestFunc(urlFunc func(f *Format) string) ([]byte, error) {
+	m := mpd{
+		Xmlns:         DASH_NAMESPACE,
+		Type:          "static",
+		Profiles:      DASH_PROFILE,
+		MinBufferTime: "PT1.5S",
+	}
+
+	var (
+		period  mpdPeriod
+		sets    = make(map[string]int) // media type -> index in period
+		longest int64
+	)
+	for i := range v.AdaptiveFormats {
+		f := &v.AdaptiveFormats[i]
+		if f.InitRange == nil || f.IndexRange == nil {
+			// can't be streamed with SegmentBase
+			continue
+		}
+
+		mime, codecs := splitMimeType(f.Video_type)
+		if mime == "" {
+			continue
+		}
+		idx, ok := sets[mime]
+		if !ok {
+			idx = len(period.AdaptationSets)
+			sets[mime] = idx
+			period.AdaptationSets = append(period.AdaptationSets, mpdAdaptationSet{
+				Id:               strconv.Itoa(idx),
+				ContentType:      strings.SplitN(mime, "/", 2)[0],
+				MimeType:         mime,
+				SegmentAlignment: true,
+				SubsegmentAlign:  true,
+			})
+		}
+
+		url := f.Url
+		if urlFunc != nil {
+			url = urlFunc(f)
+		}
+		rep := mpdRepresentation{
+			Id:        strconv.Itoa(f.Itag),
+			Codecs:    codecs,
+			Bandwidth: f.Bitrate,
+			Width:     f.Width,
+			Height:    f.Height,
+			BaseURL:   url,
+			SegmentBase: &mpdSegmentBase{
+				IndexRange:     f.IndexRange.String(),
+				Initialization: &mpdURL{Range: f.InitRange.String()},
+			},
+		}
+		if f.Fps > 0 {
+			rep.FrameRate = strconv.Itoa(f.Fps)
+		}
+		if f.AudioSampleRate > 0 {
+			rep.AudioSamplingRate = strconv.Itoa(f.AudioSampleRate)
+		}
+
+		set := &period.AdaptationSets[idx]
+		set.Representations = append(set.Representations, rep)
+		if f.ApproxDurationMs > longest {
+			longest = f.ApproxDurationMs
+		}
+	}
+	if len(period.AdaptationSets) == 0 {
+		return nil, errors.New("No adaptive formats with byte ranges available")
+	}
+
+	duration := float64(longest) / 1000
+	if longest == 0 {
+		duration = float64(v.Length_seconds)
+	}
+	m.MediaPresentationDuration = fmt.Sprintf("PT%.3fS", duration)
+	period.Duration = m.MediaPresentationDuration
+	m.Periods = []mpdPeriod{period}
+
+	data, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// split a mime type such as `video/mp4; codecs="avc1.4d401f"` into the media
+// type and codecs
+func splitMimeType(s string) (string, string) {
+	parts := strings.SplitN(s, ";", 2)
+	mime := strings.TrimSpace(parts[0])
+	var codecs string
+	if len(parts) == 2 {
+		c := strings.TrimSpace(parts[1])
+		if strings.HasPrefix(c, "codecs=") {
+			codecs = strings.Trim(strings.TrimPrefix(c, "codecs="), `"`)
+		}
+	}
+	return mime, codecs
+}
+
+// Returns the range as "start-end"
+func (r *ByteRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
@@ -0,0 +1,83 @@
+package youtube
+
+import (
+	"encoding/xml"
+	"strconv"
+	"testing"
+)
+
+func TestDASHManifest(t *testing.T) {
+	v, err := parseMeta(testVideoID, testQueryString(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another video/mp4 format shares the first one's AdaptationSet, and a
+	// format without byte ranges is left out
+	hd := v.AdaptiveFormats[0]
+	hd.Itag, hd.Height = 136, 720
+	noRanges := v.AdaptiveFormats[1]
+	noRanges.Itag, noRanges.InitRange, noRanges.IndexRange = 141, nil, nil
+	v.AdaptiveFormats = append(v.AdaptiveFormats, hd, noRanges)
+
+	data, err := v.DASHManifestFunc(func(f *Format) string {
+		return "http://127.0.0.1/" + strconv.Itoa(f.Itag)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m mpd
+	if err := xml.Unmarshal(data, &m); err != nil {
+		t.Fatalf("%s\n%s", err, data)
+	}
+	if len(m.Periods) != 1 {
+		t.Fatalf("%d periods", len(m.Periods))
+	}
+
+	want := []struct {
+		mimeType string
+		reps     []string // itag, index range and init range
+	}{
+		{"video/mp4", []string{"137 741-1188 0-740", "136 741-1188 0-740"}},
+		{"audio/mp4", []string{"140 632-895 0-631"}},
+	}
+	sets := m.Periods[0].AdaptationSets
+	if len(sets) != len(want) {
+		t.Fatalf("%d AdaptationSets, want %d\n%s", len(sets), len(want), data)
+	}
+	for i, set := range sets {
+		if set.MimeType != want[i].mimeType {
+			t.Errorf("AdaptationSet %d: %s, want %s", i, set.MimeType, want[i].mimeType)
+		}
+		if len(set.Representations) != len(want[i].reps) {
+			t.Errorf("%s: %d Representations, want %d", set.MimeType, len(set.Representations), len(want[i].reps))
+			continue
+		}
+		for j, rep := range set.Representations {
+			if rep.SegmentBase == nil || rep.SegmentBase.Initialization == nil {
+				t.Errorf("%s: Representation %s has no SegmentBase", set.MimeType, rep.Id)
+				continue
+			}
+			got := rep.Id + " " + rep.SegmentBase.IndexRange + " " + rep.SegmentBase.Initialization.Range
+			if got != want[i].reps[j] {
+				t.Errorf("%s: Representation %q, want %q", set.MimeType, got, want[i].reps[j])
+			}
+			if url := "http://127.0.0.1/" + rep.Id; rep.BaseURL != url {
+				t.Errorf("%s: BaseURL %q, want %q", rep.Id, rep.BaseURL, url)
+			}
+		}
+	}
+
+	// without a urlFunc, the format URLs are used
+	data, err = v.DASHManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = mpd{}
+	if err := xml.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Periods[0].AdaptationSets[1].Representations[0].BaseURL; got != v.AdaptiveFormats[1].Url {
+		t.Errorf("BaseURL %q, want %q", got, v.AdaptiveFormats[1].Url)
+	}
+}
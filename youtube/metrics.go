@@ -0,0 +1,57 @@
+package youtube
+
+import "time"
+
+// Receives measurements of a client's metadata requests and downloads, eg:
+// to export them to Prometheus. Methods may be called concurrently.
+type Metrics interface {
+	// a metadata request, by outcome: the video's playability status (eg:
+	// OK, LOGIN_REQUIRED, UNPLAYABLE) or "error" if it failed
+	MetadataRequest(outcome string)
+
+	// a download or live recording started
+	DownloadStarted()
+
+	// a download or live recording ended, with the bytes written and the
+	// error, if any
+	DownloadFinished(bytes int64, duration time.Duration, err error)
+
+	// a request retried: "proxy" through the next proxy of a pool, or
+	// "segment" for a live stream segment
+	Retry(kind string)
+
+	// a video's format URLs refreshed, see Video.Refresh
+	URLRefreshed()
+
+	// a post-processor ran: "mp3" or "merge"
+	PostProcessed(name string, duration time.Duration)
+}
+
+// discards measurements
+type nopMetrics struct{}
+
+func (nopMetrics) MetadataRequest(string)                       {}
+func (nopMetrics) DownloadStarted()                             {}
+func (nopMetrics) DownloadFinished(int64, time.Duration, error) {}
+func (nopMetrics) Retry(string)                                 {}
+func (nopMetrics) URLRefreshed()                                {}
+func (nopMetrics) PostProcessed(string, time.Duration)          {}
+
+// Sets the metrics of a client, and of its proxy pool, if any
+func (c *Client) SetMetrics(m Metrics) {
+	c.Metrics = m
+	if c.HTTP == nil {
+		return
+	}
+	if pool, ok := c.HTTP.Transport.(*ProxyPool); ok {
+		pool.Metrics = m
+	}
+}
+
+// the client's metrics, or ones that discard measurements
+func (c *Client) metrics() Metrics {
+	if c == nil || c.Metrics == nil {
+		return nopMetrics{}
+	}
+	return c.Metrics
+}
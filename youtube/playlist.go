@@ -0,0 +1,249 @@
+package youtube
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// holds a playlist's information and its videos in playlist order
+type Playlist struct {
+	Id      string          `json:"id"`
+	Title   string          `json:"title"`
+	Author  string          `json:"author"`
+	Entries []PlaylistEntry `json:"entries"`
+}
+
+// a video in a playlist. Deleted and private videos remain in the
+// list with IsPlayable set to false.
+type PlaylistEntry struct {
+	Index         int    `json:"index"`
+	Id            string `json:"id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	LengthSeconds int    `json:"length_seconds"`
+	IsPlayable    bool   `json:"is_playable"`
+}
+
+// a playlist page's initial data
+type playlistPage struct {
+	Contents struct {
+		TwoColumnBrowseResultsRenderer struct {
+			Tabs []struct {
+				TabRenderer struct {
+					Content struct {
+						SectionListRenderer struct {
+							Contents []struct {
+								ItemSectionRenderer struct {
+									Contents []struct {
+										PlaylistVideoListRenderer struct {
+											Contents json.RawMessage `json:"contents"`
+										} `json:"playlistVideoListRenderer"`
+									} `json:"contents"`
+								} `json:"itemSectionRenderer"`
+							} `json:"contents"`
+						} `json:"sectionListRenderer"`
+					} `json:"content"`
+				} `json:"tabRenderer"`
+			} `json:"tabs"`
+		} `json:"twoColumnBrowseResultsRenderer"`
+	} `json:"contents"`
+	Header struct {
+		PlaylistHeaderRenderer struct {
+			PlaylistID string `json:"playlistId"`
+			Title      text   `json:"title"`
+			OwnerText  text   `json:"ownerText"`
+		} `json:"playlistHeaderRenderer"`
+	} `json:"header"`
+	Metadata struct {
+		PlaylistMetadataRenderer struct {
+			Title string `json:"title"`
+		} `json:"playlistMetadataRenderer"`
+	} `json:"metadata"`
+	Alerts []struct {
+		AlertRenderer struct {
+			Type string `json:"type"`
+			Text text   `json:"text"`
+		} `json:"alertRenderer"`
+	} `json:"alerts"`
+}
+
+// an item in a playlist's video list
+type playlistItem struct {
+	PlaylistVideoRenderer *struct {
+		VideoID         string `json:"videoId"`
+		Title           text   `json:"title"`
+		Index           text   `json:"index"`
+		LengthSeconds   string `json:"lengthSeconds"`
+		ShortBylineText text   `json:"shortBylineText"`
+		IsPlayable      bool   `json:"isPlayable"`
+	} `json:"playlistVideoRenderer"`
+	ContinuationItemRenderer *continuationItem `json:"continuationItemRenderer"`
+}
+
+// extract the playlist Id from an URL
+func extractPlaylistId(input string) string {
+	if !strings.Contains(input, "list=") {
+		return input
+	}
+
+	u, err := url.Parse(input)
+	if err != nil {
+		return input
+	}
+	if id := u.Query().Get("list"); id != "" {
+		return id
+	}
+	return input
+}
+
+// given a playlist id or URL, get its information and all of its
+// videos from youtube
+func GetPlaylist(playlist_id string) (Playlist, error) {
+	return DefaultClient.GetPlaylist(playlist_id)
+}
+
+// given a playlist id or URL, get its information and all of its
+// videos from youtube
+func (c *Client) GetPlaylist(playlist_id string) (Playlist, error) {
+	playlist_id = extractPlaylistId(playlist_id)
+
+	page, err := c.fetchPage(URL_PLAYLIST + url.QueryEscape(playlist_id))
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	data, err := extractInitialData(page)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	playlist, token, err := parsePlaylistPage(data)
+	if err != nil {
+		return Playlist{}, err
+	}
+	if playlist.Id == "" {
+		playlist.Id = playlist_id
+	}
+
+	// page through the rest of the list
+	for token != "" {
+		data, err := c.browse(token)
+		if err != nil {
+			return playlist, err
+		}
+
+		var entries []PlaylistEntry
+		if entries, token, err = parsePlaylistContinuation(data); err != nil {
+			return playlist, err
+		}
+		playlist.Entries = append(playlist.Entries, entries...)
+	}
+
+	return playlist, nil
+}
+
+// parse a playlist page's initial data. Returns the playlist with its first
+// page of entries and the continuation token for the next page, if any.
+func parsePlaylistPage(data []byte) (Playlist, string, error) {
+	var page playlistPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return Playlist{}, "", fmt.Errorf("Invalid playlist page: %s", err)
+	}
+
+	// find the video list
+	var items json.RawMessage
+	for _, tab := range page.Contents.TwoColumnBrowseResultsRenderer.Tabs {
+		for _, section := range tab.TabRenderer.Content.SectionListRenderer.Contents {
+			for _, c := range section.ItemSectionRenderer.Contents {
+				if c.PlaylistVideoListRenderer.Contents != nil {
+					items = c.PlaylistVideoListRenderer.Contents
+				}
+			}
+		}
+	}
+
+	if items == nil {
+		// no such playlist
+		for _, a := range page.Alerts {
+			if a.AlertRenderer.Type == "ERROR" {
+				return Playlist{}, "", errors.New(a.AlertRenderer.Text.String())
+			}
+		}
+		if page.Header.PlaylistHeaderRenderer.PlaylistID == "" {
+			return Playlist{}, "", errors.New("No playlist found in page")
+		}
+	}
+
+	header := page.Header.PlaylistHeaderRenderer
+	playlist := Playlist{
+		Id:     header.PlaylistID,
+		Title:  header.Title.String(),
+		Author: header.OwnerText.String(),
+	}
+	if playlist.Title == "" {
+		playlist.Title = page.Metadata.PlaylistMetadataRenderer.Title
+	}
+
+	entries, token, err := parsePlaylistItems(items)
+	if err != nil {
+		return Playlist{}, "", err
+	}
+	playlist.Entries = entries
+
+	return playlist, token, nil
+}
+
+// parse a browse continuation response for a playlist. Returns the page's
+// entries and the continuation token for the next page, if any.
+func parsePlaylistContinuation(data []byte) ([]PlaylistEntry, string, error) {
+	items, err := parseContinuation(data)
+	if err != nil {
+		return nil, "", err
+	}
+	return parsePlaylistItems(items)
+}
+
+// parse the items of a playlist's video list
+func parsePlaylistItems(data json.RawMessage) ([]PlaylistEntry, string, error) {
+	if data == nil {
+		return nil, "", nil
+	}
+
+	var items []playlistItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, "", fmt.Errorf("Invalid playlist items: %s", err)
+	}
+
+	var (
+		entries []PlaylistEntry
+		token   string
+	)
+	for _, item := range items {
+		if c := item.ContinuationItemRenderer; c != nil {
+			token = c.ContinuationEndpoint.ContinuationCommand.Token
+			continue
+		}
+
+		v := item.PlaylistVideoRenderer
+		if v == nil {
+			continue
+		}
+
+		index, _ := strconv.Atoi(v.Index.String())
+		length, _ := strconv.Atoi(v.LengthSeconds)
+		entries = append(entries, PlaylistEntry{
+			Index:         index,
+			Id:            v.VideoID,
+			Title:         v.Title.String(),
+			Author:        v.ShortBylineText.String(),
+			LengthSeconds: length,
+			IsPlayable:    v.IsPlayable,
+		})
+	}
+
+	return entries, token, nil
+}
@@ -0,0 +1,101 @@
+package youtube
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// read a file in testdata
+func readTestdata(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParsePlaylistPage(t *testing.T) {
+	playlist, token, err := parsePlaylistPage(readTestdata(t, "playlist_page.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Playlist{
+		Id:     "PLtestplaylist",
+		Title:  "Rick Astley: Greatest Hits",
+		Author: "Rick Astley",
+		Entries: []PlaylistEntry{
+			{Index: 1, Id: "dQw4w9WgXcQ", Title: "Never Gonna Give You Up", Author: "Rick Astley", LengthSeconds: 213, IsPlayable: true},
+			{Index: 2, Id: "aaaaaaaaaaa", Title: "[Private video]"},
+			{Index: 3, Id: "yPYZpwSpKmA", Title: "Together Forever", Author: "Rick Astley", LengthSeconds: 205, IsPlayable: true},
+		},
+	}
+	if !reflect.DeepEqual(playlist, want) {
+		t.Errorf("got %+v\nwant %+v", playlist, want)
+	}
+	if want := "4qmFsgJhEiRWTFBMdGVzdHBsYXlsaXN0"; token != want {
+		t.Errorf("got token %q, want %q", token, want)
+	}
+}
+
+func TestParsePlaylistContinuation(t *testing.T) {
+	entries, token, err := parsePlaylistContinuation(readTestdata(t, "playlist_continuation.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []PlaylistEntry{
+		{Index: 4, Id: "BeyEGebJ1l4", Title: "Whenever You Need Somebody", Author: "Rick Astley", LengthSeconds: 236, IsPlayable: true},
+		{Index: 5, Id: "bbbbbbbbbbb", Title: "[Deleted video]"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %+v\nwant %+v", entries, want)
+	}
+	if token != "" {
+		t.Errorf("got token %q on the last page", token)
+	}
+}
+
+func TestParsePlaylistErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"missing", string(readTestdata(t, "playlist_missing.json")), "The playlist does not exist."},
+		{"empty", `{}`, "No playlist found in page"},
+		{"invalid", `[]`, "Invalid playlist page: json: cannot unmarshal array into Go value of type youtube.playlistPage"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := parsePlaylistPage([]byte(test.data))
+			if err == nil || err.Error() != test.err {
+				t.Errorf("got %v, want %q", err, test.err)
+			}
+		})
+	}
+
+	// an empty playlist has a header but no video list
+	playlist, token, err := parsePlaylistPage([]byte(`{"header": {"playlistHeaderRenderer": {"playlistId": "PLempty", "title": {"simpleText": "Empty"}}}}`))
+	if err != nil || playlist.Id != "PLempty" || playlist.Title != "Empty" || len(playlist.Entries) != 0 || token != "" {
+		t.Errorf("empty playlist: got %+v, %q, %v", playlist, token, err)
+	}
+
+	if _, _, err := parsePlaylistContinuation([]byte(`{`)); err == nil {
+		t.Error("parsed an invalid continuation")
+	}
+}
+
+func TestExtractPlaylistId(t *testing.T) {
+	tests := map[string]string{
+		"PLtestplaylist": "PLtestplaylist",
+		"https://www.youtube.com/playlist?list=PLtestplaylist":                    "PLtestplaylist",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PLtestplaylist&index=2": "PLtestplaylist",
+	}
+	for input, want := range tests {
+		if got := extractPlaylistId(input); got != want {
+			t.Errorf("extractPlaylistId(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package youtube
+
+import (
+	"io"
+	"time"
+)
+
+// a writer that reports the progress of a download about once a second
+type progressWriter struct {
+	w    io.Writer
+	fn   func(p Progress)
+	p    Progress
+	last time.Time
+	tail int64 // bytes written at the last report
+}
+
+func newProgressWriter(w io.Writer, offset, length int64, fn func(p Progress)) *progressWriter {
+	pw := &progressWriter{
+		w:    w,
+		fn:   fn,
+		p:    Progress{Downloaded: offset, Total: length},
+		last: time.Now(),
+		tail: offset,
+	}
+	fn(pw.p)
+	return pw
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.p.Downloaded += int64(n)
+	if d := time.Since(pw.last); d >= time.Second {
+		pw.report(d)
+	}
+	return n, err
+}
+
+func (pw *progressWriter) report(d time.Duration) {
+	if d > 0 {
+		pw.p.Speed = int64(float64(pw.p.Downloaded-pw.tail) / d.Seconds())
+	}
+	pw.last = time.Now()
+	pw.tail = pw.p.Downloaded
+	pw.fn(pw.p)
+}
+
+// report the final progress
+func (pw *progressWriter) done() {
+	pw.report(time.Since(pw.last))
+}
@@ -0,0 +1,152 @@
+package youtube
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Default time a proxy is left out of the rotation after a failure
+const PROXY_COOLDOWN = 5 * time.Minute
+
+// A ProxyPool is an http.RoundTripper that rotates requests through a list
+// of proxies. A proxy that fails to connect, or gets a 429 (too many requests)
+// or 403 (forbidden) response, is marked unhealthy for Cooldown, and the
+// request is retried through the next healthy proxy. If all proxies are
+// unhealthy, the one that failed first is tried.
+type ProxyPool struct {
+	// time a failed proxy is left out of the rotation
+	Cooldown time.Duration
+
+	// counts retries through the next proxy, if set
+	Metrics Metrics
+
+	mu      sync.Mutex
+	proxies []*poolProxy
+	next    int
+}
+
+// a proxy in a pool
+type poolProxy struct {
+	url       *url.URL
+	transport http.RoundTripper
+	failedAt  time.Time
+}
+
+// Returns a pool of proxies. See NewClient for the proxy URLs.
+func NewProxyPool(proxies ...string) (*ProxyPool, error) {
+	if len(proxies) == 0 {
+		return nil, errors.New("No proxies")
+	}
+
+	p := &ProxyPool{Cooldown: PROXY_COOLDOWN}
+	for _, proxy := range proxies {
+		u, err := ParseProxy(proxy)
+		if err != nil {
+			return nil, err
+		}
+		p.proxies = append(p.proxies, &poolProxy{url: u, transport: proxyTransport(u)})
+	}
+	return p, nil
+}
+
+// Returns the proxies currently in the rotation
+func (p *ProxyPool) Healthy() []*url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var healthy []*url.URL
+	now := time.Now()
+	for _, proxy := range p.proxies {
+		if p.healthy(proxy, now) {
+			healthy = append(healthy, proxy.url)
+		}
+	}
+	return healthy
+}
+
+// Sends a request through the next healthy proxy, retrying with the others
+// on failure
+func (p *ProxyPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req
+	for try := 1; ; try++ {
+		proxy := p.pick()
+		resp, err := proxy.transport.RoundTrip(r)
+		if err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusForbidden {
+			return resp, nil
+		}
+		// a cancelled request says nothing about the proxy
+		if req.Context().Err() != nil {
+			return resp, err
+		}
+		p.fail(proxy)
+
+		if try == len(p.proxies) {
+			return resp, err
+		}
+		next, ok := retryRequest(req)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if p.Metrics != nil {
+			p.Metrics.Retry("proxy")
+		}
+		r = next
+	}
+}
+
+// a copy of a request to send again, if its body can be recreated
+func retryRequest(req *http.Request) (*http.Request, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, true
+}
+
+// pick the next healthy proxy, or the one that failed first
+func (p *ProxyPool) pick() *poolProxy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for i := range p.proxies {
+		proxy := p.proxies[(p.next+i)%len(p.proxies)]
+		if p.healthy(proxy, now) {
+			p.next = (p.next + i + 1) % len(p.proxies)
+			return proxy
+		}
+	}
+
+	oldest := p.proxies[0]
+	for _, proxy := range p.proxies {
+		if proxy.failedAt.Before(oldest.failedAt) {
+			oldest = proxy
+		}
+	}
+	return oldest
+}
+
+// mark a proxy unhealthy
+func (p *ProxyPool) fail(proxy *poolProxy) {
+	p.mu.Lock()
+	proxy.failedAt = time.Now()
+	p.mu.Unlock()
+}
+
+func (p *ProxyPool) healthy(proxy *poolProxy, now time.Time) bool {
+	return proxy.failedAt.IsZero() || now.Sub(proxy.failedAt) >= p.Cooldown
+}
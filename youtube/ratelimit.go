@@ -0,0 +1,35 @@
+package youtube
+
+import (
+	"io"
+	"time"
+)
+
+// a reader that limits the read speed to a number of bytes per second
+type rateLimiter struct {
+	r     io.Reader
+	rate  int64
+	start time.Time
+	n     int64
+}
+
+func newRateLimiter(r io.Reader, rate int64) *rateLimiter {
+	return &rateLimiter{r: r, rate: rate, start: time.Now()}
+}
+
+func (l *rateLimiter) Read(p []byte) (int, error) {
+	// read at most a tenth of a second's worth at a time
+	if max := l.rate / 10; max > 0 && int64(len(p)) > max {
+		p = p[:max]
+	}
+
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+
+	// sleep until the bytes read are within the rate
+	due := time.Duration(float64(l.n) / float64(l.rate) * float64(time.Second))
+	if wait := due - time.Since(l.start); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
@@ -0,0 +1,102 @@
+package youtube
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// Filename length limits. Most filesystems allow 255 bytes; some room
+	// is left for suffixes such as " (1)" and ".info.json".
+	FILENAME_MAX_BYTES = 240
+	FILENAME_MAX_RUNES = 200
+)
+
+// characters that aren't allowed in filenames on Windows, or are path separators
+const unsafeChars = `/\<>:"|?*`
+
+// device names reserved on Windows, with or without an extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Returns name as a filename that is safe on all common filesystems. Unicode
+// letters are kept; path separators, control characters and characters not
+// allowed on Windows are replaced with "_". Reserved device names are prefixed
+// with "_", and long names are truncated, keeping the extension, to
+// FILENAME_MAX_RUNES characters and FILENAME_MAX_BYTES bytes.
+func SanitizeFilename(name string) string {
+	return fixFilename(replaceUnsafe(name))
+}
+
+// Returns path, or if a file already exists at path, the first of
+// "name (1).ext", "name (2).ext" … that doesn't exist
+func UniqueFilename(path string) string {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return path
+	}
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		p := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Lstat(p); os.IsNotExist(err) {
+			return p
+		}
+	}
+}
+
+// replace path separators, control and unsafe characters
+func replaceUnsafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r), strings.ContainsRune(unsafeChars, r):
+			return '_'
+		case unicode.IsSpace(r):
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// fix up a single path component with safe characters: trim leading and
+// trailing spaces and dots, escape reserved names and truncate
+func fixFilename(name string) string {
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return "_"
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if len(ext) > 16 || strings.ContainsRune(ext, ' ') {
+		// not an extension
+		base, ext = name, ""
+	}
+
+	if reservedNames[strings.ToUpper(strings.SplitN(base, ".", 2)[0])] {
+		base = "_" + base
+	}
+
+	// truncate by runes, then by bytes, without splitting runes
+	if n := FILENAME_MAX_RUNES - utf8.RuneCountInString(ext); utf8.RuneCountInString(base) > n {
+		base = string([]rune(base)[:n])
+	}
+	for len(base)+len(ext) > FILENAME_MAX_BYTES {
+		_, size := utf8.DecodeLastRuneInString(base)
+		base = base[:len(base)-size]
+	}
+
+	if base = strings.TrimRight(base, " ."); base == "" {
+		base = "_"
+	}
+	return base + ext
+}
@@ -0,0 +1,71 @@
+package youtube
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"A / B: C?", "A _ B_ C_"},
+		{"tab\there\x00", "tab_here_"},
+		{" .hidden. ", "hidden"},
+		{"..", "_"},
+		{"", "_"},
+		{"日本語のタイトル.mp4", "日本語のタイトル.mp4"},
+
+		// reserved Windows names, with or without an extension
+		{"CON", "_CON"},
+		{"con.txt", "_con.txt"},
+		{"LPT1.tar.gz", "_LPT1.tar.gz"},
+		{"CONSOLE.txt", "CONSOLE.txt"},
+		{"COM10", "COM10"},
+
+		// truncated by runes, then by bytes, keeping the extension
+		{strings.Repeat("a", 300) + ".mp4", strings.Repeat("a", 196) + ".mp4"},
+		{strings.Repeat("é", 150) + ".mp4", strings.Repeat("é", 118) + ".mp4"},
+		{strings.Repeat("日", 100) + ".mp4", strings.Repeat("日", 78) + ".mp4"},
+		{strings.Repeat("日", 100) + "a.mp4", strings.Repeat("日", 78) + ".mp4"},
+		{"a" + strings.Repeat(" ", 250) + "b", "a"},
+	}
+	for _, test := range tests {
+		got := SanitizeFilename(test.name)
+		if got != test.want {
+			t.Errorf("%q = %q, want %q", test.name, got, test.want)
+		}
+		if len(got) > FILENAME_MAX_BYTES || !utf8.ValidString(got) {
+			t.Errorf("%q: %q is too long or splits a rune", test.name, got)
+		}
+	}
+}
+
+func TestUniqueFilename(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"video.mp4", "video.mp4"},
+		{"video.mp4", "video (1).mp4"},
+		{"video.mp4", "video (2).mp4"},
+		{"video", "video"},
+		{"video", "video (1)"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name)
+		got := UniqueFilename(path)
+		if want := filepath.Join(dir, test.want); got != want {
+			t.Errorf("%q = %q, want %q", test.name, got, want)
+		}
+		// the next call collides with it
+		if err := os.WriteFile(got, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package youtube
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/knadh/go-get-youtube/youtube/internal/container"
+)
+
+// the size of the blocks a rangeReader fetches
+const rangeBlockSize = 64 << 10
+
+// A part of a video, in time from its start
+type Section struct {
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+}
+
+// an io.ReaderAt of a remote stream, that fetches and keeps the blocks read
+// with range requests
+type rangeReader struct {
+	ctx    context.Context
+	client *Client
+	url    string
+	size   int64
+	blocks map[int64][]byte
+}
+
+func newRangeReader(ctx context.Context, c *Client, url string, size int64) *rangeReader {
+	return &rangeReader{ctx: ctx, client: c, url: url, size: size, blocks: make(map[int64][]byte)}
+}
+
+func (r *rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > r.size {
+		end = r.size
+	}
+
+	// fetch the missing blocks, neighbouring ones with one request
+	first, last := off/rangeBlockSize, (end-1)/rangeBlockSize
+	for b := first; b <= last; {
+		if r.blocks[b] != nil {
+			b++
+			continue
+		}
+		to := b
+		for to < last && r.blocks[to+1] == nil {
+			to++
+		}
+		data, err := r.fetch(b*rangeBlockSize, (to+1)*rangeBlockSize)
+		if err != nil {
+			return 0, err
+		}
+		for i := b; i <= to; i++ {
+			block := data[(i-b)*rangeBlockSize:]
+			if len(block) > rangeBlockSize {
+				block = block[:rangeBlockSize]
+			}
+			r.blocks[i] = block
+		}
+		b = to + 1
+	}
+
+	n := 0
+	for off+int64(n) < end {
+		pos := off + int64(n)
+		n += copy(p[n:], r.blocks[pos/rangeBlockSize][pos%rangeBlockSize:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fetch the bytes from start to end, exclusive
+func (r *rangeReader) fetch(start, end int64) ([]byte, error) {
+	if end > r.size {
+		end = r.size
+	}
+	resp, err := r.client.GetRange(r.ctx, r.url, start, end-1)
+	if err != nil {
+		return nil, fmt.Errorf("Request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("Range request failed: %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, end-start))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != end-start {
+		return nil, fmt.Errorf("Range request failed: got %d of %d bytes", len(data), end-start)
+	}
+	return data, nil
+}
+
+// Downloads the part of a format from start to end to filename, without
+// the rest of the video, eg: to cut a clip of a long video. An end of 0 is
+// the end of the video. Only MP4 and WebM streams with an index of their
+// segments can be cut, which are usually the adaptive formats. Sections are
+// cut at segment boundaries, so they may start a little earlier and end a
+// little later.
+func (v *Video) DownloadSection(ctx context.Context, itag int, start, end time.Duration, filename string, option *Option) error {
+	f := v.FormatByItag(itag)
+	if f == nil {
+		return fmt.Errorf("No format with itag %d", itag)
+	}
+	log := v.client.log(option)
+
+	size := f.ContentLength
+	if size == 0 {
+		var err error
+		if size, err = v.client.ContentLength(f.Url); err != nil {
+			return err
+		}
+	}
+
+	// read the stream's header and index
+	r := newRangeReader(ctx, v.client, f.Url, size)
+	info, err := container.ProbeHeader(r, size)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("Unable to read the index of format %d: %s", itag, err)
+	}
+	if info.Index == nil {
+		return fmt.Errorf("Format %d has no index of segments, it can't be cut", itag)
+	}
+	segments := info.Index.Section(start, end)
+	if len(segments) == 0 {
+		return fmt.Errorf("The section starts after the end of the video (%s)", info.Duration)
+	}
+	header, err := info.Header(r)
+	if err != nil {
+		return fmt.Errorf("Unable to read the header of format %d: %s", itag, err)
+	}
+
+	// create the output directory
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("Unable to create directory %q: %s", dir, err)
+		}
+	}
+	out, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("Unable to write to file %q: %s", filename, err)
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	w := io.MultiWriter(out, hash)
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("Unable to write to file %q: %s", filename, err)
+	}
+
+	first, last := segments[0], segments[len(segments)-1]
+	length := last.Offset + last.Size - first.Offset
+	section := Section{Start: first.Start, End: last.Start + last.Duration}
+	log.Info("Downloading section", "from", section.Start, "to", section.End, "size", abbr(length))
+
+	m := v.client.metrics()
+	m.DownloadStarted()
+	begin := time.Now()
+	n, err := v.copySegments(ctx, f.Url, first.Offset, length, w, option)
+	m.DownloadFinished(n, time.Since(begin), err)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	v.Filename = filename
+	v.DownloadInfo = &DownloadInfo{
+		Itag:      itag,
+		Filename:  filename,
+		Size:      int64(len(header)) + n,
+		Sha256:    hex.EncodeToString(hash.Sum(nil)),
+		Timestamp: time.Now(),
+		Section:   &section,
+	}
+	log.Info("Download finished", "file", filename, "duration", time.Since(begin).Round(time.Second))
+	return nil
+}
+
+// copy length bytes of a stream from offset to w
+func (v *Video) copySegments(ctx context.Context, url string, offset, length int64, w io.Writer, option *Option) (int64, error) {
+	resp, err := v.client.GetRange(ctx, url, offset, offset+length-1)
+	if err != nil {
+		return 0, fmt.Errorf("Request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("Range request failed: %s", resp.Status)
+	}
+
+	var body io.Reader = resp.Body
+	if option.RateLimit > 0 {
+		body = newRateLimiter(body, option.RateLimit)
+	}
+	if option.Progress != nil {
+		pw := newProgressWriter(w, 0, length, option.Progress)
+		defer pw.done()
+		w = pw
+	}
+	n, err := io.Copy(w, io.LimitReader(body, length))
+	if err == nil {
+		err = checkSize(n, length, 0)
+	}
+	return n, err
+}
+
+// Returns the container format of a stream from its first bytes, or "" if
+// it isn't MP4 or WebM
+func (c *Client) detectFormat(ctx context.Context, url string) (string, error) {
+	resp, err := c.GetRange(ctx, url, 0, 4095)
+	if err != nil {
+		return "", fmt.Errorf("Media request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return "", fmt.Errorf("Media request failed: %s", resp.Status)
+	}
+	head, err := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", fmt.Errorf("Media request failed: %s", err)
+	}
+	return container.Detect(head), nil
+}
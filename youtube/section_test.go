@@ -0,0 +1,161 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knadh/go-get-youtube/youtube/internal/container"
+)
+
+// a fragmented MP4 stream of size bytes, with three segments of 3 seconds.
+// Returns its initialization data and segments.
+func testFragmentedMP4(size int) ([]byte, []byte, [][]byte) {
+	be32 := func(values ...uint32) []byte {
+		b := make([]byte, 4*len(values))
+		for i, v := range values {
+			binary.BigEndian.PutUint32(b[4*i:], v)
+		}
+		return b
+	}
+	init := append(box("ftyp", []byte("dash"), be32(0)), box("moov",
+		box("mvhd", be32(0, 0, 0, 1000, 9000), make([]byte, 80)),
+		box("mvex", box("mehd", be32(0, 9000))))...)
+	sidxSize := 8 + 24 + 3*12
+
+	var segments [][]byte
+	rest := size - len(init) - sidxSize
+	for i := 0; i < 3; i++ {
+		mdat := 100000
+		if i == 2 {
+			mdat = rest - 2*(100000+16) - 16
+		}
+		segments = append(segments, append(box("moof", []byte{byte(i)}, make([]byte, 7)), box("mdat", testMedia(mdat))...))
+	}
+	var refs []byte
+	for _, s := range segments {
+		refs = append(refs, be32(uint32(len(s)), 3000, 0x90000000)...)
+	}
+	sidx := box("sidx", be32(0, 1, 1000, 0, 0), []byte{0, 0, 0, 3}, refs)
+	stream := append(append(append([]byte{}, init...), sidx...), bytes.Join(segments, nil)...)
+	return stream, init, segments
+}
+
+func TestDownloadSection(t *testing.T) {
+	f := newFakeYoutube(t)
+	stream, init, segments := testFragmentedMP4(307200)
+	f.streams["137"] = stream
+	v, filename := testDownload(t, f)
+
+	if err := v.DownloadSection(context.Background(), 137, 4*time.Second, 6*time.Second, filename, &Option{Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(append([]byte{}, init...), segments[1]...); !bytes.Equal(data, want) {
+		t.Errorf("downloaded %d bytes, want the init data and the second segment (%d bytes)", len(data), len(want))
+	}
+	info, err := container.Probe(bytes.NewReader(data), int64(len(data)))
+	if err != nil || !info.Fragmented || info.Index != nil {
+		t.Errorf("section = %+v, %v", info, err)
+	}
+	if s := v.DownloadInfo.Section; s == nil || *s != (Section{3 * time.Second, 6 * time.Second}) {
+		t.Errorf("download section = %+v", s)
+	}
+	if err := v.VerifyDownload(); err != nil {
+		t.Errorf("verify: %s", err)
+	}
+
+	// the header in one block, the segment in one request
+	offset := len(stream) - len(segments[2]) - len(segments[1])
+	want := []string{
+		"GET /videoplayback bytes=0-65535",
+		"GET /videoplayback bytes=" + strconv.Itoa(offset) + "-" + strconv.Itoa(offset+len(segments[1])-1),
+	}
+	if got := f.mediaRequests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+
+	// the last segment, to the end
+	if err := v.DownloadSection(context.Background(), 137, 7*time.Second, 0, filename, &Option{Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	if v.DownloadInfo.Size != int64(len(init)+len(segments[2])) {
+		t.Errorf("downloaded %d bytes, want %d", v.DownloadInfo.Size, len(init)+len(segments[2]))
+	}
+}
+
+func TestDownloadSectionErrors(t *testing.T) {
+	f := newFakeYoutube(t)
+	stream, _, _ := testFragmentedMP4(307200)
+	f.streams["137"] = stream
+	v, filename := testDownload(t, f)
+
+	tests := []struct {
+		itag       int
+		start, end time.Duration
+		err        string
+	}{
+		{999, 0, 0, "No format with itag 999"},
+		{18, 0, 0, "Unable to read the index of format 18: Unknown container format"},
+		{137, 10 * time.Second, 0, "The section starts after the end of the video (9s)"},
+	}
+	for _, test := range tests {
+		err := v.DownloadSection(context.Background(), test.itag, test.start, test.end, filename, &Option{Quiet: true})
+		if err == nil || err.Error() != test.err {
+			t.Errorf("itag %d: got error %v, want %q", test.itag, err, test.err)
+		}
+	}
+}
+
+func TestGetExtension(t *testing.T) {
+	f := newFakeYoutube(t)
+	v, _ := testDownload(t, f)
+	if ext := v.GetExtension(0); ext != "mp4" {
+		t.Errorf("extension of the codec = %q, want mp4", ext)
+	}
+	if ext, err := v.DetectExtension(context.Background(), 0); ext != "mp4" || err != nil {
+		t.Errorf("detected extension of the codec = %q, %v, want mp4", ext, err)
+	}
+	requests := len(f.mediaRequests())
+
+	// unknown type, detected from the stream only by DetectExtension
+	v.Formats[0].Video_type = ""
+	if ext := v.GetExtension(0); ext != "avi" {
+		t.Errorf("extension of an unknown type = %q, want avi", ext)
+	}
+	if n := len(f.mediaRequests()); n != requests {
+		t.Errorf("GetExtension sent %d requests", n-requests)
+	}
+	if ext, err := v.DetectExtension(context.Background(), 0); ext != "avi" || err != nil {
+		t.Errorf("extension of an unknown stream = %q, %v, want avi", ext, err)
+	}
+	f.streams["18"], _, _ = testFragmentedMP4(307200)
+	if ext, err := v.DetectExtension(context.Background(), 0); ext != "mp4" || err != nil {
+		t.Errorf("extension of an MP4 stream = %q, %v, want mp4", ext, err)
+	}
+	if r := f.mediaRequests(); !strings.HasSuffix(r[len(r)-1], "bytes=0-4095") {
+		t.Errorf("requests = %q, want one of the first bytes", r)
+	}
+
+	f.getStatus = http.StatusForbidden
+	if _, err := v.DetectExtension(context.Background(), 0); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("detecting a forbidden stream: got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.getStatus = 0
+	if _, err := v.DetectExtension(ctx, 0); err == nil {
+		t.Error("detected with a cancelled context")
+	}
+}
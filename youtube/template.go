@@ -0,0 +1,245 @@
+package youtube
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Default output filename template
+	DEFAULT_TEMPLATE = "%(id)s.%(ext)s"
+
+	// Rendered in place of fields that have no value
+	TEMPLATE_NA = "NA"
+)
+
+// Renders an output filename template for a video downloaded in the given
+// format (which may be nil). Templates are strings with %(field)s style
+// placeholders:
+//
+//	%(uploader)s/%(upload_date)s - %(title)s [%(id)s].%(ext)s
+//
+// Fields are the JSON names of the Video and Format fields, plus ext,
+// uploader, channel, duration and format_id. Placeholders take the usual
+// printf flags, width and conversion (s, d, f, x), eg: %(view_count)08d.
+// Dates can be formatted with strftime directives: %(upload_date>%Y-%m-%d)s.
+// Field values are sanitised and can't contain path separators or be . or
+// .., but the template can. Every component of the rendered path is made
+// safe with the rules of SanitizeFilename.
+func RenderFilename(video *Video, format *Format, tmpl string) (string, error) {
+	return RenderFilenameWith(video, format, tmpl, nil)
+}
+
+// Renders an output filename template like RenderFilename, with extra
+// fields such as playlist_index
+func RenderFilenameWith(video *Video, format *Format, tmpl string, extra map[string]interface{}) (string, error) {
+	fields, err := templateFields(video, format)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range extra {
+		fields[k] = v
+	}
+
+	// the path components with a field value in them, by index
+	fromField := make(map[int]bool)
+
+	var out strings.Builder
+	for {
+		i := strings.IndexByte(tmpl, '%')
+		if i < 0 {
+			out.WriteString(tmpl)
+			break
+		}
+		out.WriteString(tmpl[:i])
+		tmpl = tmpl[i:]
+
+		// escaped %
+		if strings.HasPrefix(tmpl, "%%") {
+			out.WriteByte('%')
+			tmpl = tmpl[2:]
+			continue
+		}
+		if !strings.HasPrefix(tmpl, "%(") {
+			out.WriteByte('%')
+			tmpl = tmpl[1:]
+			continue
+		}
+
+		end := strings.IndexByte(tmpl, ')')
+		if end < 0 {
+			return "", errors.New("Unterminated template field")
+		}
+		name := tmpl[2:end]
+		tmpl = tmpl[end+1:]
+
+		// printf flags, width and precision up to the conversion character
+		conv := strings.IndexAny(tmpl, "sdifx")
+		if conv < 0 || strings.Trim(tmpl[:conv], "-+# 0123456789.") != "" {
+			return "", fmt.Errorf("Invalid conversion for template field %q", name)
+		}
+		spec := tmpl[:conv+1]
+		tmpl = tmpl[conv+1:]
+
+		var layout string
+		if j := strings.IndexByte(name, '>'); j >= 0 {
+			name, layout = name[:j], name[j+1:]
+		}
+
+		// values are safe on their own, so that they can't be . or ..
+		value := replaceUnsafe(formatField(fields[name], layout, spec))
+		if value != "" {
+			value = fixFilename(value)
+		}
+		fromField[strings.Count(filepath.ToSlash(out.String()), "/")] = true
+		out.WriteString(value)
+	}
+
+	// fix up every path component. Only . and .. written in the template
+	// are kept.
+	parts := strings.Split(filepath.ToSlash(out.String()), "/")
+	for i, p := range parts {
+		literal := (p == "." || p == "..") && !fromField[i]
+		if p != "" && !literal && !(i == 0 && filepath.VolumeName(p) == p) {
+			parts[i] = fixFilename(p)
+		}
+	}
+	return filepath.FromSlash(strings.Join(parts, "/")), nil
+}
+
+// collect the template fields of a video and format
+func templateFields(video *Video, format *Format) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+
+	// every JSON field of the video and format
+	sources := []interface{}{video}
+	if format != nil {
+		sources = append(sources, format)
+	}
+	for _, v := range sources {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+	}
+	delete(fields, "formats")
+	delete(fields, "adaptive_formats")
+	delete(fields, "download")
+
+	// dates are rendered as YYYYMMDD
+	dates := map[string]time.Time{
+		"publish_date":    video.PublishDate,
+		"upload_date":     video.UploadDate,
+		"scheduled_start": video.ScheduledStart,
+	}
+	for k, t := range dates {
+		if t.IsZero() {
+			delete(fields, k)
+		} else {
+			fields[k] = t
+		}
+	}
+
+	// common aliases
+	fields["uploader"] = video.Author
+	fields["channel"] = video.OwnerChannelName
+	fields["duration"] = video.Length_seconds
+	if format != nil {
+		fields["ext"] = format.extension()
+		fields["format_id"] = format.Itag
+	}
+
+	return fields, nil
+}
+
+// format a field value with a printf spec, eg: "05d"
+func formatField(value interface{}, layout, spec string) string {
+	verb := spec[len(spec)-1]
+
+	switch v := value.(type) {
+	case nil:
+		value = TEMPLATE_NA
+	case string:
+		if v == "" {
+			value = TEMPLATE_NA
+		}
+	case time.Time:
+		if layout == "" {
+			layout = "%Y%m%d"
+		}
+		value = strftime(v, layout)
+	case float64:
+		// JSON numbers
+		if verb != 'f' && v == math.Trunc(v) {
+			value = int64(v)
+		}
+	}
+
+	switch verb {
+	case 'd', 'i', 'x':
+		if _, ok := value.(string); ok {
+			// not a number, print as is
+			return fmt.Sprintf("%"+spec[:len(spec)-1]+"v", value)
+		}
+		if verb == 'i' {
+			spec = spec[:len(spec)-1] + "d"
+		}
+	case 's':
+		spec = spec[:len(spec)-1] + "v"
+	}
+	return fmt.Sprintf("%"+spec, value)
+}
+
+// format a time with strftime directives
+func strftime(t time.Time, layout string) string {
+	var out strings.Builder
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' || i == len(layout)-1 {
+			out.WriteByte(layout[i])
+			continue
+		}
+
+		i++
+		switch layout[i] {
+		case 'Y':
+			out.WriteString(strconv.Itoa(t.Year()))
+		case 'y':
+			out.WriteString(t.Format("06"))
+		case 'm':
+			out.WriteString(t.Format("01"))
+		case 'd':
+			out.WriteString(t.Format("02"))
+		case 'H':
+			out.WriteString(t.Format("15"))
+		case 'M':
+			out.WriteString(t.Format("04"))
+		case 'S':
+			out.WriteString(t.Format("05"))
+		case 'b':
+			out.WriteString(t.Format("Jan"))
+		case 'B':
+			out.WriteString(t.Format("January"))
+		case 'a':
+			out.WriteString(t.Format("Mon"))
+		case 'A':
+			out.WriteString(t.Format("Monday"))
+		case 'j':
+			out.WriteString(fmt.Sprintf("%03d", t.YearDay()))
+		case '%':
+			out.WriteByte('%')
+		default:
+			out.WriteByte('%')
+			out.WriteByte(layout[i])
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,39 @@
+package youtube
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderFilename(t *testing.T) {
+	video := &Video{Id: "testvideo01", Title: "A / B: C?", Author: "Someone"}
+	format := &Format{Itag: 18, Video_type: "video/mp4"}
+
+	tests := []struct {
+		tmpl  string
+		extra map[string]interface{}
+		want  string
+	}{
+		{DEFAULT_TEMPLATE, nil, "testvideo01.mp4"},
+		{"%(uploader)s/%(title)s [%(id)s].%(ext)s", nil, "Someone/A _ B_ C_ [testvideo01].mp4"},
+		{"%(view_count)05d-%(format_id)s.%(ext)s", nil, "00000-18.mp4"},
+		{"../out/./%(id)s.%(ext)s", nil, "../out/./testvideo01.mp4"},
+
+		// values can't traverse directories
+		{"%(playlist_title)s/%(title)s.%(ext)s", map[string]interface{}{"playlist_title": ".."}, "_/A _ B_ C_.mp4"},
+		{"%(playlist_title)s/%(id)s", map[string]interface{}{"playlist_title": "."}, "_/testvideo01"},
+		{"%(a)s%(b)s/x", map[string]interface{}{"a": ".", "b": "."}, "__/x"},
+		{"..%(a)s/x", map[string]interface{}{"a": ""}, "NA/x"},
+		{"%(a)s/x", map[string]interface{}{"a": "../.."}, "_/x"},
+	}
+	for _, test := range tests {
+		got, err := RenderFilenameWith(video, format, test.tmpl, test.extra)
+		if err != nil {
+			t.Errorf("%q: %s", test.tmpl, err)
+			continue
+		}
+		if want := filepath.FromSlash(test.want); got != want {
+			t.Errorf("%q = %q, want %q", test.tmpl, got, want)
+		}
+	}
+}
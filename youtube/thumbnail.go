@@ -0,0 +1,249 @@
+package youtube
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// Youtube thumbnail source url (video id, thumbnail name)
+	URL_THUMB = "https://i.ytimg.com/vi/%s/%s.jpg"
+
+	// Thumbnail sizes accepted by ThumbnailBySize and DownloadThumbnail
+	THUMB_BEST    = "best"
+	THUMB_MAXRES  = "maxres"
+	THUMB_SD      = "sd"
+	THUMB_HQ      = "hq"
+	THUMB_MQ      = "mq"
+	THUMB_DEFAULT = "default"
+)
+
+// well-known thumbnails served for every video, largest first.
+// maxresdefault and sddefault only exist for higher resolution uploads.
+var thumbSizes = []struct {
+	size, name    string
+	width, height int
+}{
+	{THUMB_MAXRES, "maxresdefault", 1280, 720},
+	{THUMB_SD, "sddefault", 640, 480},
+	{THUMB_HQ, "hqdefault", 480, 360},
+	{THUMB_MQ, "mqdefault", 320, 180},
+	{THUMB_DEFAULT, "default", 120, 90},
+}
+
+// a video thumbnail
+type Thumbnail struct {
+	Url    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// collect thumbnails from all sources, dropping duplicates and
+// sorting them from the smallest to the largest
+func collectThumbnails(lists ...[]Thumbnail) []Thumbnail {
+	var (
+		out  []Thumbnail
+		seen = make(map[string]bool)
+	)
+	for _, list := range lists {
+		for _, t := range list {
+			if t.Url == "" || seen[t.Url] {
+				continue
+			}
+			seen[t.Url] = true
+			out = append(out, t)
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Width*out[i].Height < out[j].Width*out[j].Height
+	})
+	return out
+}
+
+// Returns the thumbnail of the given size (maxres, sd, hq, mq, default or best).
+// Sizes not listed in the video metadata are probed on the thumbnail server,
+// falling back to the next smaller size if the requested one doesn't exist.
+func (v *Video) ThumbnailBySize(size string) (*Thumbnail, error) {
+	if size == "" {
+		size = THUMB_BEST
+	}
+
+	start := -1
+	for i, s := range thumbSizes {
+		if s.size == size {
+			start = i
+			break
+		}
+	}
+	if start < 0 && size != THUMB_BEST {
+		return nil, fmt.Errorf("Unknown thumbnail size: %s", size)
+	}
+
+	// the largest thumbnail listed in the metadata
+	var largest *Thumbnail
+	if n := len(v.Thumbnails); n > 0 {
+		largest = &v.Thumbnails[n-1]
+	}
+
+	if start < 0 {
+		start = 0
+	}
+	for _, s := range thumbSizes[start:] {
+		// best size: a listed thumbnail at least this large wins
+		if size == THUMB_BEST && largest != nil && largest.Width*largest.Height >= s.width*s.height {
+			return largest, nil
+		}
+
+		// listed in the metadata, no need to probe
+		for i := range v.Thumbnails {
+			t := &v.Thumbnails[i]
+			if strings.Contains(t.Url, "/"+s.name+".") {
+				return t, nil
+			}
+		}
+
+		url := fmt.Sprintf(URL_THUMB, v.Id, s.name)
+		if v.client.thumbExists(url) {
+			return &Thumbnail{Url: url, Width: s.width, Height: s.height}, nil
+		}
+	}
+
+	if largest != nil {
+		return largest, nil
+	}
+	return nil, errors.New("No thumbnail available")
+}
+
+// check if a thumbnail exists on the server
+func (c *Client) thumbExists(url string) bool {
+	resp, err := c.head(url)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// Downloads the thumbnail of the given size to filename. If filename has a
+// .jpg or .png extension that doesn't match the thumbnail's format, the image
+// is converted, from the JPEG version of webp thumbnails.
+func (v *Video) DownloadThumbnail(size, filename string) error {
+	thumb, err := v.ThumbnailBySize(size)
+	if err != nil {
+		return err
+	}
+
+	// Go can't decode webp, fetch the JPEG version to convert instead
+	src := thumb.Url
+	if imageFormat(filepath.Ext(filename)) != "" {
+		src = jpegThumbnail(src)
+	}
+
+	resp, err := v.client.get(src)
+	if err != nil {
+		return fmt.Errorf("Request failed: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Thumbnail request failed: %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if data, err = convertImage(data, filepath.Ext(filename)); err != nil {
+		return err
+	}
+
+	// create the output directory
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("Unable to create directory %q: %s", dir, err)
+		}
+	}
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("Unable to write to file %q: %s", filename, err)
+	}
+	return nil
+}
+
+// the image format (jpeg or png) of a file extension, or "" if it isn't
+// converted to
+func imageFormat(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".png":
+		return "png"
+	}
+	return ""
+}
+
+// the JPEG version of a webp thumbnail URL, eg: /vi_webp/ID/hqdefault.webp
+// is /vi/ID/hqdefault.jpg. Other URLs are returned as is.
+func jpegThumbnail(thumb string) string {
+	u, err := url.Parse(thumb)
+	if err != nil || !strings.HasSuffix(u.Path, ".webp") {
+		return thumb
+	}
+	u.Path = strings.Replace(strings.TrimSuffix(u.Path, ".webp")+".jpg", "/vi_webp/", "/vi/", 1)
+	// the query signs the webp URL only
+	u.RawQuery = ""
+	return u.String()
+}
+
+// check if image data is webp: a RIFF container of WEBP data
+func isWebp(data []byte) bool {
+	return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+}
+
+// convert image data to the format given by a file extension (.jpg or .png).
+// Other extensions, or images already in the target format, are left untouched.
+func convertImage(data []byte, ext string) ([]byte, error) {
+	target := imageFormat(ext)
+	if target == "" {
+		return data, nil
+	}
+	if isWebp(data) {
+		return nil, fmt.Errorf("Unable to convert a webp thumbnail to %s, save it with a .webp extension", target)
+	}
+
+	_, source, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read thumbnail image: %s", err)
+	}
+	if source == target {
+		return data, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read thumbnail image: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if target == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to convert thumbnail: %s", err)
+	}
+	return buf.Bytes(), nil
+}
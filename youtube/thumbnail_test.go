@@ -0,0 +1,55 @@
+package youtube
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestJpegThumbnail(t *testing.T) {
+	tests := map[string]string{
+		"https://i.ytimg.com/vi_webp/dQw4w9WgXcQ/maxresdefault.webp":           "https://i.ytimg.com/vi/dQw4w9WgXcQ/maxresdefault.jpg",
+		"https://i.ytimg.com/vi_webp/dQw4w9WgXcQ/hq720.webp?sqp=abc&rs=AOn4CL": "https://i.ytimg.com/vi/dQw4w9WgXcQ/hq720.jpg",
+		"https://i.ytimg.com/vi/dQw4w9WgXcQ/hqdefault.jpg":                     "https://i.ytimg.com/vi/dQw4w9WgXcQ/hqdefault.jpg",
+		"https://i.ytimg.com/vi/dQw4w9WgXcQ/hq720.jpg?sqp=abc":                 "https://i.ytimg.com/vi/dQw4w9WgXcQ/hq720.jpg?sqp=abc",
+	}
+	for thumb, want := range tests {
+		if got := jpegThumbnail(thumb); got != want {
+			t.Errorf("jpegThumbnail(%q) = %q, want %q", thumb, got, want)
+		}
+	}
+}
+
+func TestConvertImage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	jpg, err := convertImage(data, ".JPG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, format, err := image.DecodeConfig(bytes.NewReader(jpg)); err != nil || format != "jpeg" {
+		t.Errorf("converted to %q: %v", format, err)
+	}
+
+	// already in the target format, or not converted
+	for _, ext := range []string{".png", ".webp", ""} {
+		if out, err := convertImage(data, ext); err != nil || !bytes.Equal(out, data) {
+			t.Errorf("%q: image changed: %v", ext, err)
+		}
+	}
+
+	// webp can't be decoded
+	webp := []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00")
+	if _, err := convertImage(webp, ".jpg"); err == nil || !strings.Contains(err.Error(), "webp") {
+		t.Errorf("converting webp: got %v", err)
+	}
+	if out, err := convertImage(webp, ".webp"); err != nil || !bytes.Equal(out, webp) {
+		t.Errorf("webp to .webp: image changed: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package youtube
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/knadh/go-get-youtube/youtube/internal/container"
+)
+
+// Checks the file of the video's last download against the facts recorded
+// of it, eg: loaded with LoadInfoJSON. The file's size must match the
+// download's and the format's content length (unless only a section was
+// downloaded), its SHA-256 checksum must match the download's, and MP4 and
+// WebM files must be complete.
+func (v *Video) VerifyDownload() error {
+	d := v.DownloadInfo
+	if d == nil {
+		return errors.New("No download recorded")
+	}
+
+	info, err := os.Stat(d.Filename)
+	if err != nil {
+		return fmt.Errorf("Unable to read file %q: %s", d.Filename, err)
+	}
+	if info.Size() != d.Size {
+		return fmt.Errorf("Size mismatch: %d bytes, expected %d", info.Size(), d.Size)
+	}
+	if f := v.FormatByItag(d.Itag); f != nil && f.ContentLength > 0 && f.ContentLength != d.Size && d.Section == nil {
+		return fmt.Errorf("Size mismatch: %d bytes, the format has %d", d.Size, f.ContentLength)
+	}
+
+	hash := sha256.New()
+	if err := hashFile(d.Filename, hash, d.Size); err != nil {
+		return fmt.Errorf("Unable to read file %q: %s", d.Filename, err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != d.Sha256 {
+		return fmt.Errorf("Checksum mismatch: %s, expected %s", sum, d.Sha256)
+	}
+
+	return probeContainer(d.Filename)
+}
+
+// check the size of a finished download against the expected one and the
+// format's content length, if it's known
+func checkSize(size, expected, contentLength int64) error {
+	if size != expected {
+		return fmt.Errorf("Download incomplete: %d of %d bytes", size, expected)
+	}
+	if contentLength > 0 && size != contentLength {
+		return fmt.Errorf("Size mismatch: %d bytes, the format has %d", size, contentLength)
+	}
+	return nil
+}
+
+// check that an MP4 or WebM file isn't truncated. Files of other formats
+// aren't checked.
+func probeContainer(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("Unable to read file %q: %s", filename, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("Unable to read file %q: %s", filename, err)
+	}
+	if _, err := container.Probe(f, info.Size()); err != nil && err != container.ErrUnknown {
+		return fmt.Errorf("%q is damaged: %s", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package youtube
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// an MP4 box
+func box(boxType string, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	b := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint32(b, uint32(8+len(data)))
+	copy(b[4:], boxType)
+	return append(b, data...)
+}
+
+// an EBML element with a one byte size
+func element(id []byte, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	b := append(append([]byte{}, id...), 0x80|byte(len(data)))
+	return append(b, data...)
+}
+
+var (
+	ebmlMagic = []byte{0x1a, 0x45, 0xdf, 0xa3}
+	idSegment = []byte{0x18, 0x53, 0x80, 0x67}
+	idInfo    = []byte{0x15, 0x49, 0xa9, 0x66}
+	idCluster = []byte{0x1f, 0x43, 0xb6, 0x75}
+	// a segment of unknown size
+	unknownSegment = []byte{0x18, 0x53, 0x80, 0x67, 0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+func TestProbeContainer(t *testing.T) {
+	mp4 := bytes.Join([][]byte{
+		box("ftyp", []byte("isom\x00\x00\x02\x00")),
+		box("moov", box("mvhd", make([]byte, 100))),
+		box("mdat", make([]byte, 1000)),
+	}, nil)
+	header := element(ebmlMagic, []byte{0x42, 0x82, 0x84}, []byte("webm"))
+	webm := append(header, element(idSegment, element(idInfo, []byte{0x2a, 0xd7, 0xb1, 0x83, 0x0f, 0x42, 0x40}), element(idCluster, make([]byte, 50)))...)
+	live := append(append(header, unknownSegment...), element(idCluster, make([]byte, 50))...)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"mp4", mp4, ""},
+		{"truncated mp4", mp4[:len(mp4)-10], "Truncated mdat box: 998 of 1008 bytes"},
+		{"truncated mp4 header", mp4[:len(mp4)-1004], "Truncated box header at offset"},
+		{"mp4 without moov", append(box("ftyp", []byte("isom")), box("mdat", make([]byte, 10))...), "No moov box"},
+		{"webm", webm, ""},
+		{"truncated webm", webm[:len(webm)-1], "Truncated Segment"},
+		{"live webm", live, ""},
+		{"truncated live webm", live[:len(live)-1], "Truncated element 1f43b675"},
+		{"other", []byte("not a container"), ""},
+	}
+	dir := t.TempDir()
+	for _, test := range tests {
+		filename := filepath.Join(dir, strings.Replace(test.name, " ", "-", -1))
+		if err := ioutil.WriteFile(filename, test.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := probeContainer(filename)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %s", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestDownloadIncomplete(t *testing.T) {
+	f := newFakeYoutube(t)
+	f.short = 100
+	v, filename := testDownload(t, f)
+
+	err := v.Download(0, filename, &Option{Quiet: true})
+	if want := "Download incomplete: 307100 of 307200 bytes"; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestDownloadLarger(t *testing.T) {
+	f := newFakeYoutube(t)
+	v, filename := testDownload(t, f)
+	if err := ioutil.WriteFile(filename, append(f.media, 0), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := v.Download(0, filename, &Option{Quiet: true, Resume: true})
+	if err == nil || !strings.Contains(err.Error(), "is larger than the video") {
+		t.Errorf("got error %v, want a larger file error", err)
+	}
+}
+
+func TestVerifyDownload(t *testing.T) {
+	f := newFakeYoutube(t)
+	v, filename := testDownload(t, f)
+	if err := v.VerifyDownload(); err == nil || err.Error() != "No download recorded" {
+		t.Errorf("got error %v before downloading", err)
+	}
+	if err := v.Download(0, filename, &Option{Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// round trip through an info file
+	info := filepath.Join(t.TempDir(), "out.info.json")
+	if err := v.WriteInfoJSON(info); err != nil {
+		t.Fatal(err)
+	}
+	v, err := LoadInfoJSON(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyDownload(); err != nil {
+		t.Errorf("intact download: %s", err)
+	}
+
+	damaged := append([]byte{}, f.media...)
+	damaged[1000]++
+	if err := ioutil.WriteFile(filename, damaged, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyDownload(); err == nil || !strings.HasPrefix(err.Error(), "Checksum mismatch") {
+		t.Errorf("damaged download: got error %v, want a checksum mismatch", err)
+	}
+
+	if err := os.Truncate(filename, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyDownload(); err == nil || err.Error() != "Size mismatch: 1000 bytes, expected 307200" {
+		t.Errorf("truncated download: got error %v, want a size mismatch", err)
+	}
+}
+
+func TestRefreshLoadedInfo(t *testing.T) {
+	f := newFakeYoutube(t)
+	v, _ := testDownload(t, f)
+	info := filepath.Join(t.TempDir(), "out.info.json")
+	if err := v.WriteInfoJSON(info); err != nil {
+		t.Fatal(err)
+	}
+
+	// a loaded video has no client, and refreshes with the default one
+	defer func(c *Client) { DefaultClient = c }(DefaultClient)
+	DefaultClient = f.client()
+
+	v, err := LoadInfoJSON(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Formats[0].Url = ""
+	if err := v.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if v.Id != testVideoID || v.Formats[0].Url == "" {
+		t.Errorf("refreshed video %+v", v)
+	}
+}
@@ -13,6 +13,8 @@
 package youtube
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -50,16 +52,80 @@ var (
 
 // holds a video's information
 type Video struct {
-	Id, Title, Author, Keywords, Thumbnail_url string
-	Avg_rating                                 float32
-	View_count, Length_seconds                 int
-	Formats                                    []Format
-	Filename                                   string
+	Id              string      `json:"id"`
+	Title           string      `json:"title"`
+	Author          string      `json:"author"`
+	Keywords        string      `json:"keywords"`
+	Thumbnail_url   string      `json:"thumbnail_url"`
+	Avg_rating      float32     `json:"avg_rating"`
+	View_count      int         `json:"view_count"`
+	Length_seconds  int         `json:"length_seconds"`
+	Formats         []Format    `json:"formats"`
+	AdaptiveFormats []Format    `json:"adaptive_formats"`
+	Thumbnails      []Thumbnail `json:"thumbnails"`
+	Captions        []Caption   `json:"captions"`
+	Chapters        []Chapter   `json:"chapters"`
+	Filename        string      `json:"filename"`
+
+	// microformat metadata
+	Description        string    `json:"description"`
+	Category           string    `json:"category"`
+	PublishDate        time.Time `json:"publish_date"`
+	UploadDate         time.Time `json:"upload_date"`
+	IsUnlisted         bool      `json:"is_unlisted"`
+	IsLiveContent      bool      `json:"is_live_content"`
+	IsPrivate          bool      `json:"is_private"`
+	AvailableCountries []string  `json:"available_countries"`
+	OwnerChannelName   string    `json:"owner_channel_name"`
+	OwnerProfileURL    string    `json:"owner_profile_url"`
+	ChannelID          string    `json:"channel_id"`
+	ExternalChannelID  string    `json:"external_channel_id"`
+
+	// playability status, eg: OK, LOGIN_REQUIRED, UNPLAYABLE
+	PlayabilityStatus string `json:"playability_status"`
+
+	// live streams and premieres
+	IsLive          bool      `json:"is_live"`
+	IsUpcoming      bool      `json:"is_upcoming"`
+	ScheduledStart  time.Time `json:"scheduled_start"`
+	HLSManifestURL  string    `json:"hls_manifest_url"`
+	DASHManifestURL string    `json:"dash_manifest_url"`
+
+	// set by Download
+	DownloadInfo *DownloadInfo `json:"download,omitempty"`
+
+	// the client the video was fetched with
+	client *Client
 }
 
 type Format struct {
-	Itag                     int
-	Video_type, Quality, Url string
+	Itag       int    `json:"itag"`
+	Video_type string `json:"video_type"`
+	Quality    string `json:"quality"`
+	Url        string `json:"url"`
+
+	// streaming data details, where available
+	QualityLabel     string     `json:"quality_label,omitempty"`
+	Bitrate          int        `json:"bitrate,omitempty"`
+	AverageBitrate   int        `json:"average_bitrate,omitempty"`
+	Width            int        `json:"width,omitempty"`
+	Height           int        `json:"height,omitempty"`
+	Fps              int        `json:"fps,omitempty"`
+	AudioSampleRate  int        `json:"audio_sample_rate,omitempty"`
+	AudioChannels    int        `json:"audio_channels,omitempty"`
+	ContentLength    int64      `json:"content_length,omitempty"`
+	ApproxDurationMs int64      `json:"approx_duration_ms,omitempty"`
+	InitRange        *ByteRange `json:"init_range,omitempty"`
+	IndexRange       *ByteRange `json:"index_range,omitempty"`
+
+	// audio or video only
+	Adaptive bool `json:"adaptive,omitempty"`
+}
+
+// An inclusive range of bytes in a stream
+type ByteRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
 }
 
 // Download options
@@ -67,6 +133,26 @@ type Option struct {
 	Resume bool // resume failed or cancelled download
 	Rename bool // rename output file using video title
 	Mp3    bool // extract audio using ffmpeg
+
+	// record downloads in an archive
+	Archive Archive
+
+	// don't print status messages and progress
+	Quiet bool
+
+	// maximum download speed in bytes per second, 0 for no limit
+	RateLimit int64
+
+	// called with the progress of a download about once a second, and
+	// when it finishes
+	Progress func(p Progress)
+}
+
+// Progress of a download
+type Progress struct {
+	Downloaded int64 `json:"downloaded"` // bytes, including resumed ones
+	Total      int64 `json:"total"`
+	Speed      int64 `json:"speed"` // bytes per second
 }
 
 // Response Data
@@ -81,11 +167,23 @@ type playerResponse struct {
 		} `json:"serviceTrackingParams"`
 	} `json:"responseContext"`
 	PlayabilityStatus struct {
-		Status          string `json:"status"`
-		PlayableInEmbed bool   `json:"playableInEmbed"`
+		Status            string `json:"status"`
+		Reason            string `json:"reason"`
+		PlayableInEmbed   bool   `json:"playableInEmbed"`
+		LiveStreamability struct {
+			LiveStreamabilityRenderer struct {
+				OfflineSlate struct {
+					LiveStreamOfflineSlateRenderer struct {
+						ScheduledStartTime string `json:"scheduledStartTime"`
+					} `json:"liveStreamOfflineSlateRenderer"`
+				} `json:"offlineSlate"`
+			} `json:"liveStreamabilityRenderer"`
+		} `json:"liveStreamability"`
 	} `json:"playabilityStatus"`
 	StreamingData struct {
 		ExpiresInSeconds string `json:"expiresInSeconds"`
+		HlsManifestURL   string `json:"hlsManifestUrl"`
+		DashManifestURL  string `json:"dashManifestUrl"`
 		Formats          []struct {
 			Itag             int    `json:"itag"`
 			URL              string `json:"url"`
@@ -211,6 +309,8 @@ type playerResponse struct {
 		IsPrivate         bool    `json:"isPrivate"`
 		IsUnpluggedCorpus bool    `json:"isUnpluggedCorpus"`
 		IsLiveContent     bool    `json:"isLiveContent"`
+		IsLive            bool    `json:"isLive"`
+		IsUpcoming        bool    `json:"isUpcoming"`
 	} `json:"videoDetails"`
 	Annotations []struct {
 		PlayerAnnotationsUrlsRenderer struct {
@@ -274,6 +374,11 @@ type playerResponse struct {
 			PublishDate          string   `json:"publishDate"`
 			OwnerChannelName     string   `json:"ownerChannelName"`
 			UploadDate           string   `json:"uploadDate"`
+			LiveBroadcastDetails struct {
+				IsLiveNow      bool   `json:"isLiveNow"`
+				StartTimestamp string `json:"startTimestamp"`
+				EndTimestamp   string `json:"endTimestamp"`
+			} `json:"liveBroadcastDetails"`
 		} `json:"playerMicroformatRenderer"`
 	} `json:"microformat"`
 	TrackingParams string `json:"trackingParams"`
@@ -377,29 +482,39 @@ func extractId(input string) (string, error) {
 	return "", fmt.Errorf("No video ID detectable")
 }
 
-// given a video id, get it's information from youtube
-func Get(video_id string) (Video, error) {
-	if strings.Contains(video_id, "youtube.com/watch?") {
-		video_id, _ = extractId(video_id)
-	}
-	
-	// fetch video meta from youtube
-	query_string, err := fetchMeta(video_id)
-	
-	if err != nil {
-		return Video{}, err
+// Returns the video Id in a Youtube URL (youtube.com/watch?v=, youtu.be/,
+// /shorts/, /embed/ or /live/), or the input as is
+func VideoId(input string) string {
+	if strings.Contains(input, "youtube.com/watch?") {
+		if id, err := extractId(input); err == nil {
+			return id
+		}
 	}
 
-	meta, err := parseMeta(video_id, query_string)
-
-	if err != nil {
-		return Video{}, err
+	if u, err := url.Parse(input); err == nil && u.Host != "" {
+		path := strings.Split(strings.Trim(u.Path, "/"), "/")
+		switch {
+		case strings.HasSuffix(u.Host, "youtu.be") && path[0] != "":
+			return path[0]
+		case len(path) == 2 && (path[0] == "shorts" || path[0] == "embed" || path[0] == "live"):
+			return path[1]
+		}
 	}
+	return input
+}
 
-	return *meta, nil
+// given a video id, get it's information from youtube
+func Get(video_id string) (Video, error) {
+	return DefaultClient.Get(video_id)
 }
 
 func (video *Video) Download(index int, filename string, option *Option) error {
+	return video.DownloadContext(context.Background(), index, filename, option)
+}
+
+// Downloads a format like Download, stopping with ctx's error when ctx is
+// cancelled. The partial file is left for a resumed download.
+func (video *Video) DownloadContext(ctx context.Context, index int, filename string, option *Option) error {
 	var (
 		out    *os.File
 		err    error
@@ -407,6 +522,15 @@ func (video *Video) Download(index int, filename string, option *Option) error {
 		length int64
 	)
 
+	log := video.client.log(option)
+
+	// create the output directory
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("Unable to create directory %q: %s", dir, err)
+		}
+	}
+
 	if option.Resume {
 		// Resume download from last known offset
 		flags := os.O_WRONLY | os.O_CREATE
@@ -418,7 +542,7 @@ func (video *Video) Download(index int, filename string, option *Option) error {
 		if err != nil {
 			return fmt.Errorf("Unable to seek file %q: %s", filename, err)
 		}
-		fmt.Printf("Resuming from offset %d (%s)\n", offset, abbr(offset))
+		log.Info("Resuming download", "offset", offset, "size", abbr(offset))
 
 	} else {
 		// Start new download
@@ -435,39 +559,100 @@ func (video *Video) Download(index int, filename string, option *Option) error {
 	video.Filename = filename
 
 	// Get video content length
-	if resp, err := http.Head(url); err != nil {
-		return fmt.Errorf("Head request failed: %s", err)
-	} else {
-		if resp.StatusCode == 403 {
-			return errors.New("Head request failed: Video is 403 forbidden")
+	if length, err = video.client.ContentLength(url); err != nil {
+		return err
+	}
+	if length < offset {
+		return fmt.Errorf("%q is larger than the video (%d of %d bytes), download it again without resuming", filename, offset, length)
+	}
+	if length == offset {
+		log.Info("Video file is already downloaded", "file", filename)
+		if err := probeContainer(filename); err != nil {
+			return err
 		}
-
-		if size := resp.Header.Get("Content-Length"); len(size) == 0 {
-			return errors.New("Content-Length header is missing")
-		} else if length, err = strconv.ParseInt(size, 10, 64); err != nil {
-			return fmt.Errorf("Invalid Content-Length: %s", err)
+		hash := sha256.New()
+		if err := hashFile(filename, hash, offset); err != nil {
+			return fmt.Errorf("Unable to read file %q: %s", filename, err)
 		}
+		video.setDownloadInfo(index, offset, hash)
+		video.archive(option)
+		return nil
+	}
 
-		if length <= offset {
-			fmt.Println("Video file is already downloaded.")
-			return nil
+	// checksum the part of the file that was already downloaded
+	hash := sha256.New()
+	if offset > 0 {
+		if err := hashFile(filename, hash, offset); err != nil {
+			return fmt.Errorf("Unable to read file %q: %s", filename, err)
 		}
 	}
 
-	if length > 0 {
+	if length > 0 && !option.Quiet {
 		go printProgress(out, offset, length)
 	}
 
 	// Not using range requests by default, because Youtube is throttling
-	// download speed. Using a single GET request for max speed.
+	// download speed. Using a single GET request for max speed, from the
+	// offset when resuming.
+	m := video.client.metrics()
+	m.DownloadStarted()
 	start := time.Now()
-	resp, err := http.Get(url)
+	var resp *http.Response
+	if offset > 0 {
+		resp, err = video.client.GetRange(ctx, url, offset, -1)
+	} else {
+		resp, err = video.client.getContext(ctx, url)
+	}
 	if err != nil {
+		m.DownloadFinished(0, time.Since(start), err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return fmt.Errorf("Request failed: %s", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		err = fmt.Errorf("Request failed: %s", resp.Status)
+		m.DownloadFinished(0, time.Since(start), err)
+		return err
+	}
 
-	if length, err = io.Copy(out, resp.Body); err != nil {
+	// the server ignored the range, start over
+	if offset > 0 && resp.StatusCode == http.StatusOK {
+		log.Warn("Server doesn't support resuming, restarting download")
+		if err := out.Truncate(0); err != nil {
+			return fmt.Errorf("Unable to write to file %q: %s", filename, err)
+		}
+		if _, err := out.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("Unable to seek file %q: %s", filename, err)
+		}
+		offset = 0
+		hash.Reset()
+	}
+
+	var body io.Reader = resp.Body
+	if option.RateLimit > 0 {
+		body = newRateLimiter(body, option.RateLimit)
+	}
+	var w io.Writer = io.MultiWriter(out, hash)
+	if option.Progress != nil {
+		pw := newProgressWriter(w, offset, length, option.Progress)
+		defer pw.done()
+		w = pw
+	}
+	total := length
+	length, err = io.Copy(w, body)
+	if err == nil {
+		err = checkSize(offset+length, total, video.Formats[index].ContentLength)
+	}
+	m.DownloadFinished(length, time.Since(start), err)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	if err := probeContainer(filename); err != nil {
 		return err
 	}
 
@@ -481,52 +666,70 @@ func (video *Video) Download(index int, filename string, option *Option) error {
 	}
 
 	if option.Rename {
-		// Rename output file using video title
-		wspace := regexp.MustCompile(`\W+`)
-		fname := strings.Split(filename, ".")[0]
+		// Rename output file using video title, keeping letters and
+		// numbers in any script
+		wspace := regexp.MustCompile(`[^\pL\pM\pN]+`)
 		ext := filepath.Ext(filename)
-		title := wspace.ReplaceAllString(video.Title, "-")
+		fname := strings.TrimSuffix(filename, ext)
+		title := []rune(wspace.ReplaceAllString(video.Title, "-"))
 		if len(title) > 64 {
 			title = title[:64]
 		}
-		title = strings.TrimRight(strings.ToLower(title), "-")
-		video.Filename = fmt.Sprintf("%s-%s%s", fname, title, ext)
+		slug := strings.Trim(strings.ToLower(string(title)), "-")
+		name := SanitizeFilename(fmt.Sprintf("%s-%s%s", filepath.Base(fname), slug, ext))
+		video.Filename = UniqueFilename(filepath.Join(filepath.Dir(filename), name))
 		if err := os.Rename(filename, video.Filename); err != nil {
-			fmt.Println("Failed to rename output file:", err)
+			log.Warn("Failed to rename output file", "error", err)
 		}
 	}
 
+	video.setDownloadInfo(index, offset+length, hash)
+	video.archive(option)
+
 	// Extract audio from downloaded video using ffmpeg
 	if option.Mp3 {
 		if err := out.Close(); err != nil {
-			fmt.Println("Error:", err)
+			log.Warn("Unable to close file", "file", video.Filename, "error", err)
 		}
 		ffmpeg, err := exec.LookPath("ffmpeg")
 		if err != nil {
-			fmt.Println("ffmpeg not found")
+			log.Warn("ffmpeg not found, audio not extracted")
 		} else {
-			fmt.Println("Extracting audio ..")
+			log.Info("Extracting audio")
 			fname := video.Filename
 			mp3 := strings.TrimRight(fname, filepath.Ext(fname)) + ".mp3"
 			cmd := exec.Command(ffmpeg, "-y", "-loglevel", "quiet", "-i", fname, "-vn", mp3)
-			cmd.Stdin = os.Stdin
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			if err := cmd.Run(); err != nil {
-				fmt.Println("Failed to extract audio:", err)
+			if !option.Quiet {
+				cmd.Stdin = os.Stdin
+				cmd.Stdout = os.Stdout
+				cmd.Stderr = os.Stderr
+			}
+			extract := time.Now()
+			err := cmd.Run()
+			m.PostProcessed("mp3", time.Since(extract))
+			if err != nil {
+				log.Warn("Failed to extract audio", "error", err)
 			} else {
-				fmt.Println()
-				fmt.Println("Extracted audio:", mp3)
+				log.Info("Extracted audio", "file", mp3)
 			}
 		}
 	}
 
-	fmt.Printf("Download duration: %s\n", duration)
-	fmt.Printf("Average speed: %s/s\n", abbr(int64(speed)))
+	log.Info("Download finished", "duration", duration, "speed", abbr(int64(speed))+"/s")
 
 	return nil
 }
 
+// record a finished download in the archive, if any
+func (video *Video) archive(option *Option) {
+	if option.Archive == nil {
+		return
+	}
+	if err := option.Archive.Add(video.Id, video.DownloadInfo.Itag); err != nil {
+		video.client.log(option).Warn("Failed to update download archive", "error", err)
+	}
+}
+
 func abbr(byteSize int64) string {
 	size := float64(byteSize)
 	switch {
@@ -578,17 +781,147 @@ func printProgress(out *os.File, offset, length int64) {
 	}
 }
 
+// Returns the time the format's URL expires, from its expire parameter, or
+// the zero time if it's unknown
+func (f *Format) Expires() time.Time {
+	u, err := url.Parse(f.Url)
+	if err != nil {
+		return time.Time{}
+	}
+	secs, err := strconv.ParseInt(u.Query().Get("expire"), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0)
+}
+
+// Returns the format with an Itag number among both the formats and the
+// adaptive formats, or nil if unknown
+func (v *Video) FormatByItag(itag int) *Format {
+	if _, f := v.IndexByItag(itag); f != nil {
+		return f
+	}
+	for i := range v.AdaptiveFormats {
+		if v.AdaptiveFormats[i].Itag == itag {
+			return &v.AdaptiveFormats[i]
+		}
+	}
+	return nil
+}
+
 // figure out the file extension from a codec string
 func (v *Video) GetExtension(index int) string {
+	return v.Formats[index].extension()
+}
+
+// Returns the file extension of a format like GetExtension, detecting the
+// container of formats without a known type from the first bytes of their
+// stream
+func (v *Video) DetectExtension(ctx context.Context, index int) (string, error) {
+	f := &v.Formats[index]
+	if ext := f.typeExtension(); ext != "" {
+		return ext, nil
+	}
+
+	detected, err := v.client.detectFormat(ctx, f.Url)
+	if err != nil {
+		return "", err
+	}
 	for _, format := range Formats {
-		if strings.Contains(v.Formats[index].Video_type, format) {
-			return format
+		if detected == format {
+			return format, nil
 		}
 	}
+	return "avi", nil
+}
 
+func (f *Format) extension() string {
+	if ext := f.typeExtension(); ext != "" {
+		return ext
+	}
 	return "avi"
 }
 
+// the extension of a format's type, or ""
+func (f *Format) typeExtension() string {
+	for _, format := range Formats {
+		if strings.Contains(f.Video_type, format) {
+			return format
+		}
+	}
+	return ""
+}
+
+// format qualities, from the lowest to the highest
+var qualities = []string{"tiny", "small", "medium", "large", "hd720", "hd1080", "hd1440", "hd2160"}
+
+// Returns the index of the highest quality format, or -1 if there are none.
+// Of formats of the same quality, the first listed one wins.
+func (v *Video) BestFormat() int {
+	best, rank := -1, -2
+	for i := range v.Formats {
+		if r := qualityRank(v.Formats[i].Quality); r > rank {
+			best, rank = i, r
+		}
+	}
+	return best
+}
+
+// Returns the index of the format matching a selector, which is one of
+// "best", "worst", an Itag number, a quality (eg: hd720) or an extension
+// (eg: mp4, for the best format of that type)
+func (v *Video) SelectFormat(selector string) (int, error) {
+	if len(v.Formats) == 0 {
+		return 0, errors.New("No formats available")
+	}
+
+	switch selector {
+	case "", "best":
+		return v.BestFormat(), nil
+	case "worst":
+		worst, rank := 0, len(qualities)
+		for i := range v.Formats {
+			if r := qualityRank(v.Formats[i].Quality); r < rank {
+				worst, rank = i, r
+			}
+		}
+		return worst, nil
+	}
+
+	if itag, err := strconv.Atoi(selector); err == nil {
+		idx, format := v.IndexByItag(itag)
+		if format == nil {
+			return 0, fmt.Errorf("Unknown Itag number: %d", itag)
+		}
+		return idx, nil
+	}
+
+	best, rank := -1, -2
+	for i := range v.Formats {
+		f := &v.Formats[i]
+		if f.Quality != selector && f.extension() != selector {
+			continue
+		}
+		if r := qualityRank(f.Quality); r > rank {
+			best, rank = i, r
+		}
+	}
+	if best < 0 {
+		return 0, fmt.Errorf("No format matches %q", selector)
+	}
+	return best, nil
+}
+
+// rank of a format quality, -1 if unknown
+func qualityRank(quality string) int {
+	for i, q := range qualities {
+		if q == quality {
+			return i
+		}
+	}
+	return -1
+}
+
 // Returns video format index by Itag number, or nil if unknown
 func (v *Video) IndexByItag(itag int) (int, *Format) {
 	for i := range v.Formats {
@@ -601,8 +934,8 @@ func (v *Video) IndexByItag(itag int) (int, *Format) {
 }
 
 // fetch video meta from http
-func fetchMeta(video_id string) (string, error) {
-	resp, err := http.Get(URL_META + video_id)
+func (c *Client) fetchMeta(video_id string) (string, error) {
+	resp, err := c.get(URL_META + video_id)
 
 	// fetch the meta information from http
 	if err != nil {
@@ -633,11 +966,23 @@ func parseMeta(video_id, query_string string) (*Video, error) {
 
 	// collate the necessary params
 	video := &Video{
-		Id:            video_id,
-		Title:         player_response.VideoDetails.Title,
-		Author:        player_response.VideoDetails.Author,
-		Keywords:      fmt.Sprint(player_response.VideoDetails.Keywords),
-		Thumbnail_url: player_response.VideoDetails.Thumbnail.Thumbnails[0].URL,
+		Id:       video_id,
+		Title:    player_response.VideoDetails.Title,
+		Author:   player_response.VideoDetails.Author,
+		Keywords: fmt.Sprint(player_response.VideoDetails.Keywords),
+	}
+
+	// thumbnails are listed in both the video details and the microformat
+	var thumbs [2][]Thumbnail
+	for _, t := range player_response.VideoDetails.Thumbnail.Thumbnails {
+		thumbs[0] = append(thumbs[0], Thumbnail{Url: t.URL, Width: t.Width, Height: t.Height})
+	}
+	for _, t := range player_response.Microformat.PlayerMicroformatRenderer.Thumbnail.Thumbnails {
+		thumbs[1] = append(thumbs[1], Thumbnail{Url: t.URL, Width: t.Width, Height: t.Height})
+	}
+	video.Thumbnails = collectThumbnails(thumbs[:]...)
+	if n := len(video.Thumbnails); n > 0 {
+		video.Thumbnail_url = video.Thumbnails[n-1].Url
 	}
 
 	v, _ := strconv.Atoi(player_response.VideoDetails.ViewCount)
@@ -648,11 +993,56 @@ func parseMeta(video_id, query_string string) (*Video, error) {
 	l, _ := strconv.Atoi(player_response.VideoDetails.LengthSeconds)
 	video.Length_seconds = l
 
+	// microformat metadata
+	mf := player_response.Microformat.PlayerMicroformatRenderer
+	video.Description = mf.Description.SimpleText
+	if video.Description == "" {
+		video.Description = player_response.VideoDetails.ShortDescription
+	}
+	video.Category = mf.Category
+	video.PublishDate = parseDate(mf.PublishDate)
+	video.UploadDate = parseDate(mf.UploadDate)
+	video.IsUnlisted = mf.IsUnlisted
+	video.IsLiveContent = player_response.VideoDetails.IsLiveContent
+	video.IsPrivate = player_response.VideoDetails.IsPrivate
+	video.AvailableCountries = mf.AvailableCountries
+	video.OwnerChannelName = mf.OwnerChannelName
+	video.OwnerProfileURL = mf.OwnerProfileURL
+	video.ChannelID = player_response.VideoDetails.ChannelID
+	video.ExternalChannelID = mf.ExternalChannelID
+	video.PlayabilityStatus = player_response.PlayabilityStatus.Status
+
+	// live streams have manifests instead of progressive formats
+	video.IsLive = player_response.VideoDetails.IsLive || mf.LiveBroadcastDetails.IsLiveNow
+	video.IsUpcoming = player_response.VideoDetails.IsUpcoming
+	video.HLSManifestURL = player_response.StreamingData.HlsManifestURL
+	video.DASHManifestURL = player_response.StreamingData.DashManifestURL
+	slate := player_response.PlayabilityStatus.LiveStreamability.LiveStreamabilityRenderer.OfflineSlate
+	if start, err := strconv.ParseInt(slate.LiveStreamOfflineSlateRenderer.ScheduledStartTime, 10, 64); err == nil {
+		video.ScheduledStart = time.Unix(start, 0)
+	} else if video.IsUpcoming {
+		video.ScheduledStart = parseDate(mf.LiveBroadcastDetails.StartTimestamp)
+	}
+
+	for _, c := range player_response.Captions.PlayerCaptionsTracklistRenderer.CaptionTracks {
+		video.Captions = append(video.Captions, Caption{
+			Url:            c.BaseURL,
+			Name:           c.Name.SimpleText,
+			LanguageCode:   c.LanguageCode,
+			Kind:           c.Kind,
+			IsTranslatable: c.IsTranslatable,
+		})
+	}
+	video.Chapters = parseChapters(video.Description, video.Length_seconds)
+
 	// further decode the format data
 	format_params := strings.Split(query.Get("url_encoded_fmt_stream_map"), ",")
 
 	// every video has multiple format choices. collate the list.
 	for _, f := range format_params {
+		if f == "" {
+			continue
+		}
 		furl, _ := url.Parse("?" + f)
 		fquery := furl.Query()
 
@@ -666,5 +1056,85 @@ func parseMeta(video_id, query_string string) (*Video, error) {
 		})
 	}
 
+	// formats in the streaming data, with audio and video together
+	sd := player_response.StreamingData
+	for _, f := range sd.Formats {
+		if _, known := video.IndexByItag(f.Itag); known != nil || f.URL == "" {
+			continue
+		}
+		video.Formats = append(video.Formats, Format{
+			Itag:             f.Itag,
+			Video_type:       f.MimeType,
+			Quality:          f.Quality,
+			Url:              f.URL,
+			QualityLabel:     f.QualityLabel,
+			Bitrate:          f.Bitrate,
+			AverageBitrate:   f.AverageBitrate,
+			Width:            f.Width,
+			Height:           f.Height,
+			AudioSampleRate:  atoi(f.AudioSampleRate),
+			AudioChannels:    f.AudioChannels,
+			ContentLength:    atoi64(f.ContentLength),
+			ApproxDurationMs: atoi64(f.ApproxDurationMs),
+		})
+	}
+
+	// and adaptive formats, with audio or video only
+	for _, f := range sd.AdaptiveFormats {
+		if f.URL == "" {
+			continue
+		}
+		video.AdaptiveFormats = append(video.AdaptiveFormats, Format{
+			Itag:             f.Itag,
+			Video_type:       f.MimeType,
+			Quality:          f.Quality,
+			Url:              f.URL,
+			QualityLabel:     f.QualityLabel,
+			Bitrate:          f.Bitrate,
+			AverageBitrate:   f.AverageBitrate,
+			Width:            f.Width,
+			Height:           f.Height,
+			Fps:              f.Fps,
+			AudioSampleRate:  atoi(f.AudioSampleRate),
+			AudioChannels:    f.AudioChannels,
+			ContentLength:    atoi64(f.ContentLength),
+			ApproxDurationMs: atoi64(f.ApproxDurationMs),
+			InitRange:        parseByteRange(f.InitRange.Start, f.InitRange.End),
+			IndexRange:       parseByteRange(f.IndexRange.Start, f.IndexRange.End),
+			Adaptive:         true,
+		})
+	}
+
 	return video, nil
 }
+
+// a byte range of two numeric strings, or nil if it's missing
+func parseByteRange(start, end string) *ByteRange {
+	s, err1 := strconv.ParseInt(start, 10, 64)
+	e, err2 := strconv.ParseInt(end, 10, 64)
+	if err1 != nil || err2 != nil || e < s {
+		return nil
+	}
+	return &ByteRange{Start: s, End: e}
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func atoi64(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+// parse a microformat date. Youtube sends either a plain date (2019-10-30)
+// or a full timestamp with a zone offset (2019-10-30T07:00:12-07:00).
+func parseDate(date string) time.Time {
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,389 @@
+package youtube
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knadh/go-get-youtube/youtube/internal/replay"
+)
+
+var (
+	record  = flag.Bool("record", false, "record Youtube's responses for -video to testdata/fixtures")
+	videoID = flag.String("video", "", "the video of the recorded fixtures in testdata/fixtures")
+)
+
+// the get_video_info response of testdata/player_response.json
+func testQueryString(t *testing.T) string {
+	t.Helper()
+	data, err := ioutil.ReadFile("testdata/player_response.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return url.Values{"status": {"ok"}, "player_response": {string(data)}}.Encode()
+}
+
+func TestParseMeta(t *testing.T) {
+	v, err := parseMeta(testVideoID, testQueryString(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"Id", v.Id, testVideoID},
+		{"Title", v.Title, "A Test Video: Ünïcode & Symbols!"},
+		{"Author", v.Author, "Test Channel"},
+		{"Keywords", v.Keywords, "[test video]"},
+		{"View_count", v.View_count, 12345},
+		{"Length_seconds", v.Length_seconds, 180},
+		{"Avg_rating", v.Avg_rating, float32(4.5)},
+		{"Thumbnail_url", v.Thumbnail_url, "https://i.ytimg.com/vi/testvideo01/hqdefault.jpg"},
+		{"Category", v.Category, "Education"},
+		{"PublishDate", v.PublishDate, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"AvailableCountries", v.AvailableCountries, []string{"DE", "IN", "US"}},
+		{"ChannelID", v.ChannelID, "UCtestchannel"},
+		{"PlayabilityStatus", v.PlayabilityStatus, "OK"},
+		{"IsLive", v.IsLive, false},
+		{"Chapters", v.Chapters, []Chapter{{"Intro", 0, 60}, {"Middle", 60, 150}, {"Outro", 150, 180}}},
+	}
+	for _, c := range checks {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s = %#v, want %#v", c.name, c.got, c.want)
+		}
+	}
+
+	if len(v.Formats) != 1 {
+		t.Fatalf("got %d formats, want 1", len(v.Formats))
+	}
+	f := v.Formats[0]
+	if f.Itag != 18 || f.QualityLabel != "360p" || f.ContentLength != 307200 || f.AudioSampleRate != 44100 || f.Adaptive {
+		t.Errorf("format 18 = %+v", f)
+	}
+
+	if len(v.AdaptiveFormats) != 2 {
+		t.Fatalf("got %d adaptive formats, want 2", len(v.AdaptiveFormats))
+	}
+	a := v.AdaptiveFormats[0]
+	if !a.Adaptive || a.Fps != 30 || a.Height != 1080 {
+		t.Errorf("format 137 = %+v", a)
+	}
+	if !reflect.DeepEqual(a.InitRange, &ByteRange{0, 740}) || !reflect.DeepEqual(a.IndexRange, &ByteRange{741, 1188}) {
+		t.Errorf("format 137 ranges = %v, %v", a.InitRange, a.IndexRange)
+	}
+
+	if len(v.Captions) != 2 {
+		t.Fatalf("got %d captions, want 2", len(v.Captions))
+	}
+	if c := v.Captions[1]; c.LanguageCode != "de" || c.Kind != "asr" || c.Name != "German (auto-generated)" {
+		t.Errorf("caption = %+v", c)
+	}
+}
+
+func TestParseMetaError(t *testing.T) {
+	_, err := parseMeta("missing", "status=fail&errorcode=100&reason=Video+unavailable")
+	if err == nil || err.Error() != "Video unavailable" {
+		t.Errorf("got error %v, want Video unavailable", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	c := newFakeYoutube(t).client()
+
+	v, err := c.Get("https://www.youtube.com/watch?v=" + testVideoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Id != testVideoID || v.Title == "" || len(v.Formats) == 0 {
+		t.Errorf("got video %+v", v)
+	}
+
+	if _, err := c.Get("nosuchvideo"); err == nil {
+		t.Error("got no error for an unavailable video")
+	}
+}
+
+// fetch the test video and return it with a file to download to
+func testDownload(t *testing.T, f *fakeYoutube) (Video, string) {
+	t.Helper()
+	v, err := f.client().Get(testVideoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v, filepath.Join(t.TempDir(), "out.mp4")
+}
+
+// check that a file and the video's download info match the media
+func checkDownload(t *testing.T, f *fakeYoutube, v Video, filename string) {
+	t.Helper()
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(f.media) {
+		t.Errorf("downloaded %d bytes that don't match the media", len(data))
+	}
+
+	sum := sha256.Sum256(f.media)
+	want := &DownloadInfo{Itag: 18, Filename: filename, Size: int64(len(f.media)), Sha256: hex.EncodeToString(sum[:])}
+	if v.DownloadInfo == nil {
+		t.Fatal("no download info")
+	}
+	got := *v.DownloadInfo
+	got.Timestamp = time.Time{}
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("download info = %+v, want %+v", got, *want)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	f := newFakeYoutube(t)
+	v, filename := testDownload(t, f)
+
+	if err := v.Download(0, filename, &Option{Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, f, v, filename)
+
+	want := []string{"HEAD /videoplayback ", "GET /videoplayback "}
+	if got := f.mediaRequests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	f := newFakeYoutube(t)
+	v, filename := testDownload(t, f)
+	if err := ioutil.WriteFile(filename, f.media[:1000], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.Download(0, filename, &Option{Quiet: true, Resume: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, f, v, filename)
+
+	want := []string{"HEAD /videoplayback ", "GET /videoplayback bytes=1000-"}
+	if got := f.mediaRequests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestDownloadResumeWithoutRange(t *testing.T) {
+	f := newFakeYoutube(t)
+	f.noRange = true
+	v, filename := testDownload(t, f)
+	if err := ioutil.WriteFile(filename, make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the whole media is downloaded again
+	if err := v.Download(0, filename, &Option{Quiet: true, Resume: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, f, v, filename)
+}
+
+func TestDownloadFinished(t *testing.T) {
+	f := newFakeYoutube(t)
+	v, filename := testDownload(t, f)
+	if err := ioutil.WriteFile(filename, f.media, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.Download(0, filename, &Option{Quiet: true, Resume: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, f, v, filename)
+
+	want := []string{"HEAD /videoplayback "}
+	if got := f.mediaRequests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestDownloadProgress(t *testing.T) {
+	f := newFakeYoutube(t)
+	v, filename := testDownload(t, f)
+	if err := ioutil.WriteFile(filename, f.media[:1000], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var progress []Progress
+	option := &Option{Quiet: true, Resume: true, Progress: func(p Progress) {
+		progress = append(progress, p)
+	}}
+	if err := v.Download(0, filename, option); err != nil {
+		t.Fatal(err)
+	}
+
+	total := int64(len(f.media))
+	if len(progress) < 2 {
+		t.Fatalf("got %d progress reports, want at least 2", len(progress))
+	}
+	if first := progress[0]; first.Downloaded != 1000 || first.Total != total {
+		t.Errorf("first progress = %+v, want 1000 of %d", first, total)
+	}
+	if last := progress[len(progress)-1]; last.Downloaded != total || last.Total != total {
+		t.Errorf("last progress = %+v, want %d of %d", last, total, total)
+	}
+}
+
+func TestDownloadRename(t *testing.T) {
+	f := newFakeYoutube(t)
+	v, filename := testDownload(t, f)
+
+	if err := v.Download(0, filename, &Option{Quiet: true, Rename: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := filepath.Join(filepath.Dir(filename), "out-a-test-video-ünïcode-symbols.mp4")
+	if v.Filename != want {
+		t.Errorf("renamed to %q, want %q", v.Filename, want)
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("%q still exists", filename)
+	}
+	checkDownload(t, f, v, want)
+}
+
+func TestDownloadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(f *fakeYoutube)
+		err   string
+	}{
+		{"forbidden", func(f *fakeYoutube) { f.headStatus = http.StatusForbidden }, "Head request failed: Video is 403 forbidden"},
+		{"no length", func(f *fakeYoutube) { f.noLength = true }, "Content-Length header is missing"},
+		{"failed", func(f *fakeYoutube) { f.getStatus = http.StatusInternalServerError }, "Request failed: 500 Internal Server Error"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeYoutube(t)
+			test.setup(f)
+			v, filename := testDownload(t, f)
+
+			err := v.Download(0, filename, &Option{Quiet: true})
+			if err == nil || err.Error() != test.err {
+				t.Errorf("got error %v, want %q", err, test.err)
+			}
+			if v.DownloadInfo != nil {
+				t.Errorf("got download info %+v for a failed download", v.DownloadInfo)
+			}
+		})
+	}
+}
+
+func TestDownloadCancelled(t *testing.T) {
+	f := newFakeYoutube(t)
+	v, filename := testDownload(t, f)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := v.DownloadContext(ctx, 0, filename, &Option{Quiet: true}); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDownloadCaption(t *testing.T) {
+	f := newFakeYoutube(t)
+	v, _ := testDownload(t, f)
+	dir := t.TempDir()
+
+	tests := []struct {
+		lang, format string
+		want, err    string
+	}{
+		{"en", "vtt", "Hello (en)", ""},
+		{"de", "vtt", "Hello (de)", ""},
+		{"fr", "vtt", "Hello (fr)", ""}, // translated
+		{"en", "srt", "", "Unknown caption format: srt"},
+		{"en", "srv3", "", "Caption request failed: 400 Bad Request"},
+	}
+	for _, test := range tests {
+		// in a directory that doesn't exist yet
+		filename := filepath.Join(dir, test.lang, test.lang+"."+test.format)
+		err := v.DownloadCaption(test.lang, test.format, filename)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s %s: got error %v, want %q", test.lang, test.format, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %s: %s", test.lang, test.format, err)
+			continue
+		}
+		data, _ := ioutil.ReadFile(filename)
+		if !strings.Contains(string(data), test.want) {
+			t.Errorf("%s %s: got %q, want %q", test.lang, test.format, data, test.want)
+		}
+	}
+}
+
+// record the fake server's responses, then download from the fixtures
+// without it
+func TestReplay(t *testing.T) {
+	f := newFakeYoutube(t)
+	dir := t.TempDir()
+	rec, err := (&Client{
+		HTTP:   &http.Client{Transport: &replay.Transport{Dir: dir, Mode: replay.Record, Next: f}},
+		Logger: f.client().Logger,
+	}).Get(testVideoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Download(0, filepath.Join(t.TempDir(), "out.mp4"), &Option{Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	c := &Client{HTTP: &http.Client{Transport: replay.New(dir)}, Logger: f.client().Logger}
+	v, err := c.Get(testVideoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "out.mp4")
+	if err := v.Download(0, filename, &Option{Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, f, v, filename)
+}
+
+// the fixtures of a real video, recorded with:
+//
+//	go test -run TestFixtures -record -video ID
+func TestFixtures(t *testing.T) {
+	dir := filepath.Join("testdata", "fixtures")
+	transport := replay.New(dir)
+	if *record {
+		transport.Mode = replay.Record
+	}
+	if *videoID == "" {
+		t.Skip("no -video to test")
+	}
+
+	c := &Client{HTTP: &http.Client{Transport: transport}}
+	v, err := c.Get(*videoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Title == "" || len(v.Formats) == 0 {
+		t.Fatalf("got video %+v", v)
+	}
+	if err := v.Download(0, filepath.Join(t.TempDir(), "out"), &Option{Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+}